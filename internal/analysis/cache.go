@@ -0,0 +1,230 @@
+package analysis
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/Hofsiedge/ChessOpeningAnalyzer/internal/engine"
+	"github.com/Hofsiedge/ChessOpeningAnalyzer/internal/positions"
+	"github.com/notnil/chess"
+)
+
+// Cache stores search results by engine and normalized FEN (see positions.TruncateFEN) together with
+// the limits they were searched with. Only the deepest result is kept for every position. Cache is safe for concurrent use
+type Cache struct {
+	mu sync.Mutex
+	// Entries maps an engine name (including its version, as reported by `id name`) to its results
+	Entries map[string]map[positions.FEN]*CacheEntry
+}
+
+// CacheEntry is a cached search result
+type CacheEntry struct {
+	Result *engine.Result
+	// Limit is the limit of the search that produced Result
+	Limit engine.Limit
+}
+
+func NewCache() *Cache {
+	return &Cache{Entries: make(map[string]map[positions.FEN]*CacheEntry)}
+}
+
+// cacheFile is the encoded form of Cache. Results is the layout of the caches saved before
+// the limits were stored with the results
+type cacheFile struct {
+	Entries map[string]map[positions.FEN]*CacheEntry
+	Results map[string]map[positions.FEN]*engine.Result
+}
+
+// LoadCache decodes a cache from a file generated with Cache.Dump. A missing file yields an empty cache.
+// The limits of the results of older caches are unknown, so they are treated as searched to the depth they reached
+func LoadCache(path string) (*Cache, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return NewCache(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var decoded cacheFile
+	if err = gob.NewDecoder(file).Decode(&decoded); err != nil {
+		return nil, fmt.Errorf("analysis.LoadCache: %w", err)
+	}
+	cache := NewCache()
+	for engineName, entries := range decoded.Entries {
+		for fen, entry := range entries {
+			cache.store(engineName, fen, entry)
+		}
+	}
+	for engineName, results := range decoded.Results {
+		for fen, result := range results {
+			cache.store(engineName, fen, &CacheEntry{Result: result, Limit: engine.Limit{Depth: result.Depth}})
+		}
+	}
+	return cache, nil
+}
+
+// Dump merges the cache with the one currently stored at path, so that concurrent users of the same file
+// do not lose each other's results, and replaces the file atomically keeping its permissions
+func (c *Cache) Dump(path string) error {
+	stored, err := LoadCache(path)
+	if err != nil {
+		return err
+	}
+	c.Merge(stored)
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if err = file.Chmod(mode); err != nil {
+		file.Close()
+		return err
+	}
+	c.mu.Lock()
+	err = gob.NewEncoder(file).Encode(c)
+	c.mu.Unlock()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+// Lookup returns a result of the engine for the position that is at least as good as a search with the limit
+// would give and has at least multiPV lines or a line for every legal move, see covers
+func (c *Cache) Lookup(engineName, fen string, limit engine.Limit, multiPV int) (*engine.Result, bool) {
+	c.mu.Lock()
+	entry, found := c.Entries[engineName][positions.TruncateFEN(fen)]
+	c.mu.Unlock()
+	if !found || !entry.covers(limit) {
+		return nil, false
+	}
+	if lines := len(entry.Result.Lines); lines < multiPV && lines < legalMoves(fen) {
+		return nil, false
+	}
+	return entry.Result, true
+}
+
+// legalMoves returns the number of legal moves in the position given as a complete FEN
+func legalMoves(fen string) int {
+	position, err := chess.FEN(fen)
+	if err != nil {
+		return 0
+	}
+	return len(chess.NewGame(position).ValidMoves())
+}
+
+// covers reports whether the search of the entry was at least as strong as a search with the limit.
+// A search stops at the first of its limits, so that is the case if the result reached the depth or
+// the number of nodes the limit stops at, or if none of the limits of the entry is stricter than the limit.
+// E.g. a result searched for a second does not cover a 10 second search even if the limit has no depth
+func (e *CacheEntry) covers(limit engine.Limit) bool {
+	if (limit.Depth > 0 && e.Result.Depth >= limit.Depth) || (limit.Nodes > 0 && e.Result.Nodes >= int64(limit.Nodes)) {
+		return true
+	}
+	// zero limits are unlimited
+	notStricter := func(searched, requested int64) bool {
+		return searched == 0 || (requested != 0 && searched >= requested)
+	}
+	return notStricter(int64(e.Limit.Depth), int64(limit.Depth)) &&
+		notStricter(int64(e.Limit.Nodes), int64(limit.Nodes)) &&
+		notStricter(int64(e.Limit.MoveTime), int64(limit.MoveTime))
+}
+
+// Store saves the result of a search with the limit unless a deeper one is already cached
+func (c *Cache) Store(engineName, fen string, limit engine.Limit, result *engine.Result) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.store(engineName, positions.TruncateFEN(fen), &CacheEntry{Result: result, Limit: limit})
+}
+
+func (c *Cache) store(engineName string, fen positions.FEN, entry *CacheEntry) {
+	entries, found := c.Entries[engineName]
+	if !found {
+		entries = make(map[positions.FEN]*CacheEntry)
+		c.Entries[engineName] = entries
+	}
+	if cached, found := entries[fen]; found && cached.Result.Depth > entry.Result.Depth {
+		return
+	}
+	entries[fen] = entry
+}
+
+// Merge adds the results of other to the cache, deeper results win
+func (c *Cache) Merge(other *Cache) {
+	if other == c {
+		return
+	}
+	other.mu.Lock()
+	defer other.mu.Unlock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for engineName, entries := range other.Entries {
+		for fen, entry := range entries {
+			c.store(engineName, fen, entry)
+		}
+	}
+}
+
+// Len returns the number of cached results
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	total := 0
+	for _, entries := range c.Entries {
+		total += len(entries)
+	}
+	return total
+}
+
+// CachingEvaluator looks results up in Cache before calling Evaluator and stores the new ones
+type CachingEvaluator struct {
+	Evaluator Evaluator
+	Cache     *Cache
+	// EngineName identifies the engine of Evaluator in the cache
+	EngineName string
+	// MultiPV is the number of lines Evaluator reports
+	MultiPV int
+
+	mu   sync.Mutex
+	hits int
+}
+
+// Evaluate implements Evaluator interface
+func (e *CachingEvaluator) Evaluate(ctx context.Context, fen string, limit engine.Limit) (*engine.Result, error) {
+	if result, found := e.Cache.Lookup(e.EngineName, fen, limit, e.MultiPV); found {
+		e.mu.Lock()
+		e.hits++
+		e.mu.Unlock()
+		return result, nil
+	}
+	result, err := e.Evaluator.Evaluate(ctx, fen, limit)
+	if err != nil {
+		return nil, err
+	}
+	e.Cache.Store(e.EngineName, fen, limit, result)
+	return result, nil
+}
+
+// Hits returns the number of results taken from the cache
+func (e *CachingEvaluator) Hits() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.hits
+}
@@ -0,0 +1,174 @@
+package analysis
+
+import (
+	"context"
+	"encoding/gob"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Hofsiedge/ChessOpeningAnalyzer/internal/engine"
+)
+
+const (
+	startingFEN = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+	e4FEN       = "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 1"
+)
+
+func TestCache(t *testing.T) {
+	cache := NewCache()
+	shallow := &engine.Result{Depth: 10, Score: engine.Score{Centipawns: 20}, Lines: make([]engine.Line, 1)}
+	deep := &engine.Result{Depth: 20, Score: engine.Score{Centipawns: 30}, Lines: make([]engine.Line, 1)}
+	cache.Store("Stockfish 16", startingFEN, engine.Limit{Depth: 20}, deep)
+	cache.Store("Stockfish 16", startingFEN, engine.Limit{Depth: 10}, shallow)
+	// move counters are not a part of the key
+	if result, found := cache.Lookup("Stockfish 16", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 5 10",
+		engine.Limit{Depth: 15}, 1); !found || result != deep {
+		t.Errorf("expected the deeper result to win, got %+v", result)
+	}
+	// an en passant square is a part of the key if the capture is legal
+	epFEN := "rnbqkb1r/ppp1pppp/5n2/3pP3/8/8/PPPP1PPP/RNBQKBNR w KQkq d6 0 3"
+	ep := NewCache()
+	ep.Store("Stockfish 16", epFEN, engine.Limit{Depth: 20}, deep)
+	if _, found := ep.Lookup("Stockfish 16", "rnbqkb1r/ppp1pppp/5n2/3pP3/8/8/PPPP1PPP/RNBQKBNR w KQkq - 0 3",
+		engine.Limit{Depth: 15}, 1); found {
+		t.Error("expected a position without an en passant capture to be a different one")
+	}
+	if result, found := ep.Lookup("Stockfish 16", epFEN, engine.Limit{Depth: 15}, 1); !found || result != deep {
+		t.Errorf("expected the result of the position with an en passant capture, got %+v", result)
+	}
+	if _, found := cache.Lookup("Stockfish 15", startingFEN, engine.Limit{Depth: 15}, 1); found {
+		t.Error("expected results of other engines to be ignored")
+	}
+	if _, found := cache.Lookup("Stockfish 16", startingFEN, engine.Limit{Depth: 25}, 1); found {
+		t.Error("expected shallower results to be ignored")
+	}
+	if _, found := cache.Lookup("Stockfish 16", startingFEN, engine.Limit{Depth: 15}, 3); found {
+		t.Error("expected results with fewer lines to be ignored")
+	}
+	// a position with a single legal move cannot have more lines
+	forcedFEN := "7k/8/8/8/8/8/8/K5R1 b - - 0 1"
+	forced := NewCache()
+	forced.Store("Stockfish 16", forcedFEN, engine.Limit{Depth: 20}, deep)
+	if result, found := forced.Lookup("Stockfish 16", forcedFEN, engine.Limit{Depth: 15}, 3); !found || result != deep {
+		t.Errorf("expected a line for every legal move to be enough, got %+v", result)
+	}
+
+	path := filepath.Join(t.TempDir(), "analysis_cache.bin")
+	if err := cache.Dump(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// another user stores a deeper result for the same position and a new one
+	other := NewCache()
+	deeper := &engine.Result{Depth: 30, Score: engine.Score{Centipawns: 25}}
+	other.Store("Stockfish 16", startingFEN, engine.Limit{Depth: 30}, deeper)
+	other.Store("Stockfish 16", e4FEN, engine.Limit{Depth: 10}, shallow)
+	if err := other.Dump(path); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("expected the replaced cache to keep 0600, got %v", info.Mode())
+	}
+	loaded, err := LoadCache(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Len() != 2 {
+		t.Errorf("expected 2 cached results, got %v", loaded.Len())
+	}
+	if result, _ := loaded.Lookup("Stockfish 16", startingFEN, engine.Limit{Depth: 25}, 0); !reflect.DeepEqual(result, deeper) {
+		t.Errorf("expected %+v, got %+v", deeper, result)
+	}
+
+	if empty, err := LoadCache("../../testdata/non-existent-cache.bin"); err != nil || empty.Len() != 0 {
+		t.Errorf("expected an empty cache, got %v (%v)", empty, err)
+	}
+}
+
+func TestCache_Limits(t *testing.T) {
+	cache := NewCache()
+	cache.Store("Stockfish 16", startingFEN, engine.Limit{MoveTime: 10 * time.Millisecond}, &engine.Result{Depth: 1, Nodes: 40})
+	cache.Store("Stockfish 16", e4FEN, engine.Limit{Depth: 22, MoveTime: time.Second}, &engine.Result{Depth: 18, Nodes: 900000})
+	tests := []struct {
+		name  string
+		fen   string
+		limit engine.Limit
+		found bool
+	}{
+		{"ShorterSearch", startingFEN, engine.Limit{MoveTime: 5 * time.Millisecond}, true},
+		{"LongerSearch", startingFEN, engine.Limit{MoveTime: time.Second}, false},
+		{"DeeperSearch", startingFEN, engine.Limit{Depth: 20}, false},
+		{"NodesReached", e4FEN, engine.Limit{Nodes: 500000}, true},
+		{"DepthReached", e4FEN, engine.Limit{Depth: 18, MoveTime: 5 * time.Second}, true},
+		{"SameTimeDeeper", e4FEN, engine.Limit{Depth: 20, MoveTime: time.Second}, true},
+		{"StricterDepth", e4FEN, engine.Limit{MoveTime: time.Second}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, found := cache.Lookup("Stockfish 16", tt.fen, tt.limit, 0); found != tt.found {
+				t.Errorf("expected found: %v for %+v", tt.found, tt.limit)
+			}
+		})
+	}
+
+	// the results of caches saved without the limits count as searched to the depth they reached
+	path := filepath.Join(t.TempDir(), "analysis_cache_legacy.bin")
+	defer os.Remove(path)
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = gob.NewEncoder(file).Encode(struct {
+		Results map[string]map[string]*engine.Result
+	}{map[string]map[string]*engine.Result{"Stockfish 16": {
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq": {Depth: 20},
+	}}})
+	file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy, err := LoadCache(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, found := legacy.Lookup("Stockfish 16", startingFEN, engine.Limit{Depth: 20}, 0); !found {
+		t.Error("expected a result of an older cache to be found")
+	}
+	if _, found := legacy.Lookup("Stockfish 16", startingFEN, engine.Limit{MoveTime: time.Second}, 0); found {
+		t.Error("expected a result of an older cache not to cover a search without a depth")
+	}
+}
+
+func TestCachingEvaluator(t *testing.T) {
+	cache := NewCache()
+	cache.Store("Fake", e4FEN, engine.Limit{Depth: 30}, &engine.Result{Depth: 30, Score: engine.Score{Centipawns: -5}})
+	evaluator := &CachingEvaluator{
+		Evaluator:  &constantEvaluator{score: engine.Score{Centipawns: 50}},
+		Cache:      cache,
+		EngineName: "Fake",
+	}
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		for fen, expected := range map[string]int{startingFEN: 50, e4FEN: -5} {
+			result, err := evaluator.Evaluate(ctx, fen, engine.Limit{Depth: 20})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Score.Centipawns != expected {
+				t.Errorf("%v: expected %v cp, got %+v", fen, expected, result)
+			}
+		}
+	}
+	if calls := evaluator.Evaluator.(*constantEvaluator).calls; calls != 1 || evaluator.Hits() != 3 {
+		t.Errorf("expected 1 engine call and 3 cache hits, got %v calls and %v hits", calls, evaluator.Hits())
+	}
+}
@@ -0,0 +1,326 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Hofsiedge/ChessOpeningAnalyzer/internal/engine"
+	"github.com/Hofsiedge/ChessOpeningAnalyzer/internal/positions"
+)
+
+// Evaluator evaluates a single position given as a complete FEN.
+// It is implemented by *engine.Engine and *engine.Pool
+type Evaluator interface {
+	Evaluate(ctx context.Context, fen string, limit engine.Limit) (*engine.Result, error)
+}
+
+type EvaluateOptions struct {
+	Limit engine.Limit
+	// Workers is the number of concurrent Evaluate calls. The evaluator has to be safe
+	// for concurrent use if Workers > 1 (e.g. *engine.Pool of the same size)
+	Workers int
+	// Force re-evaluates positions that were evaluated at a lower depth than Limit.Depth, so it requires
+	// a Limit.Depth. Otherwise every evaluated position is skipped
+	Force bool
+	// Checkpoint is called from the calling goroutine every CheckpointEvery evaluated positions
+	// and every CheckpointInterval, whichever comes first. Zero values disable the corresponding trigger.
+	// It is not called after an error, including its own
+	Checkpoint         func(Stats) error
+	CheckpointEvery    int
+	CheckpointInterval time.Duration
+	// Budget is the total wall-clock time of the evaluation, zero means no budget. It is split between
+	// the positions in proportion to the number of times they were reached in the games and replaces
+	// Limit.MoveTime. Time saved by searches that end early (e.g. at Limit.Depth) goes to the remaining positions
+	Budget time.Duration
+	// Source is stored with every evaluation, normally it is the name of the engine
+	Source string
+}
+
+// minMoveTime is the shortest search a budget assigns to a position
+const minMoveTime = 10 * time.Millisecond
+
+// budget splits the remaining time of an evaluation between the remaining positions. It is safe for concurrent use
+type budget struct {
+	mu              sync.Mutex
+	deadline        time.Time
+	workers         int
+	remainingWeight int
+}
+
+// moveTime returns the search time of a position with the weight and removes the weight from the remaining ones
+func (b *budget) moveTime(weight int) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	remaining := time.Until(b.deadline)
+	share := remaining
+	if b.remainingWeight > weight {
+		// the workers search in parallel, so each of them has the whole remaining time
+		share = time.Duration(float64(remaining) * float64(b.workers*weight) / float64(b.remainingWeight))
+		if share > remaining {
+			share = remaining
+		}
+	}
+	b.remainingWeight -= weight
+	if share < minMoveTime {
+		return minMoveTime
+	}
+	return share
+}
+
+// reachCounts returns the number of times every position of the graph was reached in the games.
+// The starting positions are reached in every game of their colour
+func reachCounts(graph *positions.PositionGraph) map[*positions.PositionNode]int {
+	nodes := append(graph.Nodes(), graph.WhitePositions, graph.BlackPositions)
+	counts := make(map[*positions.PositionNode]int, len(nodes))
+	for _, node := range nodes {
+		for _, move := range node.Moves {
+			counts[move.To] += move.Played
+		}
+	}
+	for _, root := range []*positions.PositionNode{graph.WhitePositions, graph.BlackPositions} {
+		for _, move := range root.Moves {
+			counts[root] += move.Played
+		}
+	}
+	return counts
+}
+
+// pendingPosition is a position to evaluate with its nodes in both repertoires
+type pendingPosition struct {
+	fen   positions.FEN
+	nodes []*positions.PositionNode
+	// weight is the number of times the position was reached in the games of both colours
+	weight int
+}
+
+// pendingPositions groups the nodes of the graph by position. Positions that do not need an evaluation are skipped,
+// their evaluations are copied to the nodes of the other colour if those have not been evaluated
+func (opts EvaluateOptions) pendingPositions(graph *positions.PositionGraph) (pending []*pendingPosition, skipped int) {
+	counts := reachCounts(graph)
+	byKey := make(map[positions.Key]*pendingPosition)
+	for _, node := range append(graph.Nodes(), graph.WhitePositions, graph.BlackPositions) {
+		position, found := byKey[node.Position.Key]
+		if !found {
+			position = &pendingPosition{fen: node.Position.FEN}
+			byKey[node.Position.Key] = position
+		}
+		position.nodes = append(position.nodes, node)
+		position.weight += counts[node]
+	}
+	pending = make([]*pendingPosition, 0, len(byKey))
+	for _, position := range byKey {
+		var done *positions.PositionNode
+		for _, node := range position.nodes {
+			if !opts.needsEvaluation(node.Position) {
+				done = node
+				break
+			}
+		}
+		if done == nil {
+			if position.weight < 1 {
+				// graphs saved before the moves were counted
+				position.weight = 1
+			}
+			pending = append(pending, position)
+			continue
+		}
+		skipped++
+		for _, node := range position.nodes {
+			if opts.needsEvaluation(node.Position) {
+				node.Position.Evaluated = true
+				node.Position.Evaluation = done.Position.Evaluation
+				node.Alternatives = done.Alternatives
+			}
+		}
+	}
+	// the most frequent positions go first, so that an interrupted evaluation covers the most important ones
+	sort.Slice(pending, func(i, j int) bool {
+		if pending[i].weight != pending[j].weight {
+			return pending[i].weight > pending[j].weight
+		}
+		return pending[i].fen < pending[j].fen
+	})
+	return pending, skipped
+}
+
+// needsEvaluation reports whether the position has to be (re-)evaluated
+func (opts EvaluateOptions) needsEvaluation(p *positions.Position) bool {
+	if !p.Evaluated {
+		return true
+	}
+	return opts.Force && p.Evaluation.Depth < opts.Limit.Depth
+}
+
+// Stats describes the throughput of an evaluation
+type Stats struct {
+	Evaluated int
+	// Skipped is the number of positions that did not need an evaluation
+	Skipped int
+	Nodes   int64
+	Elapsed time.Duration
+}
+
+// PositionsPerSecond returns the number of evaluated positions per second
+func (s Stats) PositionsPerSecond() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Evaluated) / s.Elapsed.Seconds()
+}
+
+// NodesPerSecond returns the number of searched nodes per second summed over all the workers
+func (s Stats) NodesPerSecond() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Nodes) / s.Elapsed.Seconds()
+}
+
+// String implements fmt.Stringer interface
+func (s Stats) String() string {
+	return fmt.Sprintf("%v positions in %v (%.1f positions/s, %.0f nodes/s)",
+		s.Evaluated, s.Elapsed.Round(time.Millisecond), s.PositionsPerSecond(), s.NodesPerSecond())
+}
+
+type evaluation struct {
+	position *pendingPosition
+	result   *engine.Result
+}
+
+// EvaluateGraph evaluates the positions of the graph and stores the evaluations from white's point of view.
+// Positions are taken from a shared queue by opts.Workers workers while the graph itself
+// is only modified by the calling goroutine, so it is safe to dump it in opts.Checkpoint.
+// On error (including cancellation of ctx) the already evaluated positions keep their scores,
+// so calling EvaluateGraph again resumes the evaluation
+func EvaluateGraph(ctx context.Context, graph *positions.PositionGraph, evaluator Evaluator, opts EvaluateOptions) (Stats, error) {
+	if opts.Workers < 1 {
+		opts.Workers = 1
+	}
+	if opts.Force && opts.Limit.Depth < 1 {
+		return Stats{}, fmt.Errorf("analysis.EvaluateGraph: Force requires Limit.Depth, got: %v", opts.Limit.Depth)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stats := Stats{}
+	pending, skipped := opts.pendingPositions(graph)
+	stats.Skipped = skipped
+	totalWeight := 0
+	for _, position := range pending {
+		totalWeight += position.weight
+	}
+
+	start := time.Now()
+	timeBudget := &budget{
+		deadline:        start.Add(opts.Budget),
+		workers:         opts.Workers,
+		remainingWeight: totalWeight,
+	}
+
+	queue := make(chan *pendingPosition)
+	results := make(chan evaluation, opts.Workers)
+	errs := make(chan error, opts.Workers)
+	wg := sync.WaitGroup{}
+	wg.Add(opts.Workers)
+	for i := 0; i < opts.Workers; i++ {
+		go func() {
+			defer wg.Done()
+			for position := range queue {
+				limit := opts.Limit
+				if opts.Budget > 0 {
+					limit.MoveTime = timeBudget.moveTime(position.weight)
+				}
+				result, err := evaluator.Evaluate(ctx, position.fen.Full(), limit)
+				if err != nil {
+					errs <- fmt.Errorf("analysis.EvaluateGraph: could not evaluate %v: %w", position.fen, err)
+					return
+				}
+				results <- evaluation{position, result}
+			}
+		}()
+	}
+	go func() {
+		defer close(queue)
+		for _, position := range pending {
+			select {
+			case queue <- position:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+		close(errs)
+	}()
+
+	var (
+		firstErr        error
+		lastCheckpoint  = stats.Evaluated
+		checkpointTimer <-chan time.Time
+	)
+	if opts.Checkpoint != nil && opts.CheckpointInterval > 0 {
+		ticker := time.NewTicker(opts.CheckpointInterval)
+		defer ticker.Stop()
+		checkpointTimer = ticker.C
+	}
+	checkpoint := func() {
+		if firstErr != nil {
+			return
+		}
+		stats.Elapsed = time.Since(start)
+		lastCheckpoint = stats.Evaluated
+		if err := opts.Checkpoint(stats); err != nil {
+			firstErr = fmt.Errorf("analysis.EvaluateGraph: checkpoint failed: %w", err)
+			cancel()
+		}
+	}
+	for results != nil || errs != nil {
+		select {
+		case <-checkpointTimer:
+			if stats.Evaluated != lastCheckpoint {
+				checkpoint()
+			}
+		case e, ok := <-results:
+			if !ok {
+				results = nil
+				continue
+			}
+			evaluation := newEvaluation(e.position.fen, e.result)
+			evaluation.Source = opts.Source
+			alternatives := newAlternatives(e.position.fen, e.result)
+			for i := range alternatives {
+				alternatives[i].Source = opts.Source
+			}
+			for _, node := range e.position.nodes {
+				node.Position.Evaluation = evaluation
+				node.Alternatives = alternatives
+				node.Position.Evaluated = true
+			}
+			stats.Evaluated++
+			stats.Nodes += e.result.Nodes
+			if opts.Checkpoint != nil && opts.CheckpointEvery > 0 && stats.Evaluated-lastCheckpoint >= opts.CheckpointEvery {
+				checkpoint()
+			}
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			if firstErr == nil {
+				firstErr = err
+				cancel()
+			}
+		}
+	}
+	stats.Elapsed = time.Since(start)
+	if firstErr == nil && stats.Evaluated < len(pending) {
+		// the context was cancelled before the queue was handed out to the workers
+		firstErr = fmt.Errorf("analysis.EvaluateGraph: %w", ctx.Err())
+	}
+	return stats, firstErr
+}
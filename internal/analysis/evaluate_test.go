@@ -0,0 +1,258 @@
+package analysis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Hofsiedge/ChessOpeningAnalyzer/internal/engine"
+	"github.com/Hofsiedge/ChessOpeningAnalyzer/internal/fetching"
+	"github.com/Hofsiedge/ChessOpeningAnalyzer/internal/positions"
+)
+
+// constantEvaluator reports the same score from the side to move's point of view for every position
+type constantEvaluator struct {
+	sync.Mutex
+	score engine.Score
+	err   error
+	calls int
+	// fens and limits record the searches in the order of the calls
+	fens   []string
+	limits []engine.Limit
+}
+
+func (e *constantEvaluator) Evaluate(_ context.Context, fen string, limit engine.Limit) (*engine.Result, error) {
+	e.Lock()
+	defer e.Unlock()
+	e.calls++
+	e.fens = append(e.fens, fen)
+	e.limits = append(e.limits, limit)
+	if e.err != nil {
+		return nil, e.err
+	}
+	if len(strings.Split(fen, " ")) != 6 {
+		return nil, errors.New("incomplete FEN")
+	}
+	return &engine.Result{Depth: limit.Depth, Nodes: 10, Score: e.score}, nil
+}
+
+func newTestGraph(t *testing.T, variations ...string) *positions.PositionGraph {
+	graph, _ := positions.NewPositionGraph(4)
+	for _, variation := range variations {
+		game := fetching.UserGame{
+			White:   true,
+			EndTime: time.Date(2021, 7, 8, 0, 0, 0, 0, time.UTC),
+			Moves:   strings.Split(variation, " "),
+		}
+		if err := graph.AddGame(game); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return graph
+}
+
+func TestEvaluateGraph(t *testing.T) {
+	for _, workers := range []int{1, 4} {
+		t.Run(fmt.Sprintf("%d workers", workers), func(t *testing.T) {
+			graph := newTestGraph(t, "e4 e5 Nf3 Nc6", "e4 c5")
+			evaluator := &constantEvaluator{score: engine.Score{Centipawns: 50}}
+			opts := EvaluateOptions{Limit: engine.Limit{Depth: 1}, Workers: workers}
+			stats, err := EvaluateGraph(context.Background(), graph, evaluator, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// the starting position is evaluated as well
+			if stats.Evaluated != 6 || stats.Nodes != 60 || evaluator.calls != 6 {
+				t.Errorf("expected 6 evaluated positions, got %+v (%v calls)", stats, evaluator.calls)
+			}
+			for _, node := range append(graph.Nodes(), graph.WhitePositions) {
+				fen := node.Position.FEN
+				expected := 50
+				if !fen.WhiteToMove() {
+					expected = -50
+				}
+				if e := node.Position.Evaluation; !node.Position.Evaluated || e.Centipawns != expected || e.Depth != 1 {
+					t.Errorf("%v: expected %v cp at depth 1, got %+v", fen, expected, e)
+				}
+			}
+
+			evaluator.err = engine.ErrEngineClosed
+			graph = newTestGraph(t, "d4 d5")
+			if _, err = EvaluateGraph(context.Background(), graph, evaluator, opts); !errors.Is(err, engine.ErrEngineClosed) {
+				t.Errorf("expected %v, got %v", engine.ErrEngineClosed, err)
+			}
+		})
+	}
+}
+
+func TestStats(t *testing.T) {
+	stats := Stats{Evaluated: 10, Nodes: 5000, Elapsed: 2 * time.Second}
+	if s := stats.String(); s != "10 positions in 2s (5.0 positions/s, 2500 nodes/s)" {
+		t.Errorf("unexpected stats: %q", s)
+	}
+	if (Stats{}).PositionsPerSecond() != 0 {
+		t.Error("expected zero throughput for zero elapsed time")
+	}
+}
+
+// TestEvaluateGraph_LoadedGraph evaluates a graph loaded from a file and checks that the scores are saved and printed
+func TestEvaluateGraph_LoadedGraph(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "analysis_graph.bin")
+	if err := positions.DumpGraph(newTestGraph(t, "e4 e5 Nf3", "e4 c5"), path); err != nil {
+		t.Fatal(err)
+	}
+	graph, err := positions.LoadGraph(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	evaluator := &constantEvaluator{score: engine.Score{Centipawns: 50}}
+	stats, err := EvaluateGraph(context.Background(), graph, evaluator, EvaluateOptions{Limit: engine.Limit{Depth: 1}, Workers: 1})
+	if err != nil || stats.Evaluated != 5 {
+		t.Fatalf("expected 5 evaluated positions, got %v (%v)", stats.Evaluated, err)
+	}
+	if err = positions.DumpGraph(graph, path); err != nil {
+		t.Fatal(err)
+	}
+	if graph, err = positions.LoadGraph(path); err != nil {
+		t.Fatal(err)
+	}
+	if printed := graph.String(); strings.Count(printed, " -> ") != 4 {
+		t.Errorf("expected the scores of all 4 moves to be printed, got:\n%v", printed)
+	}
+}
+
+func TestEvaluateGraph_Resume(t *testing.T) {
+	graph := newTestGraph(t, "e4 e5 Nf3 Nc6", "e4 c5")
+	evaluator := &constantEvaluator{score: engine.Score{Centipawns: 50}}
+	checkpoints := make([]int, 0)
+	opts := EvaluateOptions{
+		Limit:   engine.Limit{Depth: 1},
+		Workers: 1,
+		Checkpoint: func(stats Stats) error {
+			checkpoints = append(checkpoints, stats.Evaluated)
+			if len(checkpoints) == 2 {
+				return errors.New("disk is full")
+			}
+			return nil
+		},
+		CheckpointEvery: 2,
+	}
+	stats, err := EvaluateGraph(context.Background(), graph, evaluator, opts)
+	if err == nil || !strings.Contains(err.Error(), "disk is full") {
+		t.Errorf("expected a checkpoint error, got %v", err)
+	}
+	if !reflect.DeepEqual(checkpoints, []int{2, 4}) {
+		t.Errorf("expected checkpoints after 2 and 4 positions, got %v", checkpoints)
+	}
+
+	opts.Checkpoint = nil
+	resumed, err := EvaluateGraph(context.Background(), graph, evaluator, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resumed.Evaluated+resumed.Skipped != 6 || resumed.Skipped != stats.Evaluated {
+		t.Errorf("expected to skip %v evaluated positions, got %+v", stats.Evaluated, resumed)
+	}
+
+	// nothing is left to evaluate unless forced to search deeper
+	if stats, _ = EvaluateGraph(context.Background(), graph, evaluator, opts); stats.Evaluated != 0 {
+		t.Errorf("expected no evaluations, got %+v", stats)
+	}
+	opts.Force = true
+	if stats, _ = EvaluateGraph(context.Background(), graph, evaluator, opts); stats.Evaluated != 0 {
+		t.Errorf("expected no evaluations at the same depth, got %+v", stats)
+	}
+	opts.Limit.Depth = 2
+	evaluator.score = engine.Score{Centipawns: 80}
+	if stats, _ = EvaluateGraph(context.Background(), graph, evaluator, opts); stats.Evaluated != 6 {
+		t.Errorf("expected 6 forced evaluations, got %+v", stats)
+	}
+	for _, node := range graph.WhitePositionIndex {
+		if e := node.Position.Evaluation; e.Depth != 2 || (e.Centipawns != 80 && e.Centipawns != -80) {
+			t.Errorf("%v: expected a re-evaluated score, got %v", node.Position.FEN, e)
+		}
+	}
+	// the depth of an evaluation cannot be compared with a time limit
+	opts.Limit = engine.Limit{MoveTime: time.Second}
+	if _, err = EvaluateGraph(context.Background(), graph, evaluator, opts); err == nil {
+		t.Error("expected an error forcing an evaluation without a depth")
+	}
+}
+
+func TestEvaluateGraph_Cancel(t *testing.T) {
+	graph := newTestGraph(t, "e4 e5 Nf3 Nc6")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	evaluator := &constantEvaluator{err: context.Canceled}
+	stats, err := EvaluateGraph(ctx, graph, evaluator, EvaluateOptions{Limit: engine.Limit{Depth: 1}, Workers: 2})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected %v, got %v", context.Canceled, err)
+	}
+	if stats.Evaluated != 0 {
+		t.Errorf("expected no evaluations, got %+v", stats)
+	}
+}
+
+func TestEvaluateGraph_Budget(t *testing.T) {
+	graph := newTestGraph(t, "e4 e5", "e4 c5", "e4 c5")
+	evaluator := &constantEvaluator{}
+	opts := EvaluateOptions{Limit: engine.Limit{Depth: 20}, Budget: 600 * time.Millisecond}
+	if _, err := EvaluateGraph(context.Background(), graph, evaluator, opts); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"8/4P3/8/PPPP1PPP", "8/8/8/8/PPPPPPPP", "2p5/4P3/8/PPPP1PPP", "4p3/4P3/8/PPPP1PPP"}
+	for i, fen := range evaluator.fens {
+		if !strings.Contains(fen, expected[i]) {
+			t.Errorf("expected the positions to be evaluated from the most frequent one, got %v", evaluator.fens)
+			break
+		}
+	}
+	// the positions were reached 9 times, e4 in 3 games: it gets a third of the budget
+	if limit := evaluator.limits[0]; limit.Depth != 20 || limit.MoveTime > 200*time.Millisecond || limit.MoveTime < 180*time.Millisecond {
+		t.Errorf("expected depth 20 and a third of the budget for e4, got %+v", limit)
+	}
+	// the searches end instantly, so the last position gets the rest of the budget
+	if limit := evaluator.limits[3]; limit.MoveTime < 500*time.Millisecond {
+		t.Errorf("expected the rest of the budget for the last position, got %+v", limit)
+	}
+}
+
+func TestEvaluateGraph_BothColours(t *testing.T) {
+	graph := newTestGraph(t, "e4 e5")
+	if err := graph.AddGame(fetching.UserGame{Moves: []string{"e4", "c5"}}); err != nil {
+		t.Fatal(err)
+	}
+	evaluator := &constantEvaluator{score: engine.Score{Centipawns: 20}}
+	opts := EvaluateOptions{Limit: engine.Limit{Depth: 1}}
+	stats, err := EvaluateGraph(context.Background(), graph, evaluator, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the starting position and 1. e4 are shared by both repertoires but evaluated once
+	if stats.Evaluated != 4 || evaluator.calls != 4 {
+		t.Errorf("expected 4 evaluated positions, got %+v (%v calls)", stats, evaluator.calls)
+	}
+	for _, node := range graph.Nodes() {
+		if !node.Position.Evaluated {
+			t.Errorf("%v: expected an evaluation", node.Position.FEN)
+		}
+	}
+
+	// a position evaluated in one repertoire only is copied to the other one
+	if err = graph.AddGame(fetching.UserGame{Moves: []string{"e4", "e5"}}); err != nil {
+		t.Fatal(err)
+	}
+	if stats, err = EvaluateGraph(context.Background(), graph, evaluator, opts); err != nil {
+		t.Fatal(err)
+	}
+	blackE5 := graph.BlackPositions.Moves[0].To.Moves[1].To
+	if stats.Evaluated != 0 || evaluator.calls != 4 || blackE5.Position.Evaluation.Centipawns != 20 {
+		t.Errorf("expected the evaluation to be copied, got %+v and %v", stats, blackE5.Position.Evaluation)
+	}
+}
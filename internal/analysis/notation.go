@@ -0,0 +1,80 @@
+package analysis
+
+import (
+	"github.com/Hofsiedge/ChessOpeningAnalyzer/internal/engine"
+	"github.com/Hofsiedge/ChessOpeningAnalyzer/internal/positions"
+	"github.com/notnil/chess"
+)
+
+// toSAN converts a line of moves in UCI notation played from the position into SAN.
+// The conversion stops at the first move that is not legal in the position it is played from
+func toSAN(fen string, moves []string) []string {
+	startingPosition, err := chess.FEN(fen)
+	if err != nil {
+		return nil
+	}
+	game := chess.NewGame(startingPosition)
+	san := make([]string, 0, len(moves))
+	for _, m := range moves {
+		position := game.Position()
+		move, err := chess.UCINotation{}.Decode(position, m)
+		if err != nil {
+			break
+		}
+		if err = game.Move(move); err != nil {
+			break
+		}
+		san = append(san, chess.AlgebraicNotation{}.Encode(position, move))
+	}
+	return san
+}
+
+// lineEvaluation converts a line reported for the position into an evaluation from white's point of view.
+// BestMove of the evaluation is the first move of the line
+func lineEvaluation(fen positions.FEN, line engine.Line) positions.Evaluation {
+	evaluation := positions.Evaluation{
+		Perspective: positions.SideToMovePerspective,
+		WhiteToMove: fen.WhiteToMove(),
+		Centipawns:  line.Score.Centipawns,
+		Mate:        line.Score.Mate,
+		MateIn:      line.Score.MateIn,
+		Depth:       line.Depth,
+		PV:          toSAN(fen.Full(), line.PV),
+	}
+	if line.WDL != nil {
+		evaluation.WDL = &positions.WDL{Win: line.WDL.Win, Draw: line.WDL.Draw, Loss: line.WDL.Loss}
+	}
+	if len(evaluation.PV) > 0 {
+		evaluation.BestMove = evaluation.PV[0]
+	}
+	return evaluation.WithPerspective(positions.WhitePerspective)
+}
+
+// newEvaluation converts a search result for the position into an evaluation from white's point of view
+func newEvaluation(fen positions.FEN, result *engine.Result) positions.Evaluation {
+	evaluation := lineEvaluation(fen, engine.Line{
+		Depth: result.Depth,
+		Score: result.Score,
+		WDL:   result.WDL,
+		PV:    result.PV,
+	})
+	evaluation.Nodes = result.Nodes
+	if result.BestMove != "" && (len(result.PV) == 0 || result.PV[0] != result.BestMove) {
+		evaluation.BestMove = ""
+		if san := toSAN(fen.Full(), []string{result.BestMove}); len(san) == 1 {
+			evaluation.BestMove = san[0]
+		}
+	}
+	return evaluation
+}
+
+// newAlternatives converts every line of a search result for the position into an evaluation
+func newAlternatives(fen positions.FEN, result *engine.Result) []positions.Evaluation {
+	alternatives := make([]positions.Evaluation, 0, len(result.Lines))
+	for _, line := range result.Lines {
+		if alternative := lineEvaluation(fen, line); alternative.BestMove != "" {
+			alternatives = append(alternatives, alternative)
+		}
+	}
+	return alternatives
+}
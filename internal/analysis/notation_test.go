@@ -0,0 +1,87 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Hofsiedge/ChessOpeningAnalyzer/internal/engine"
+	"github.com/Hofsiedge/ChessOpeningAnalyzer/internal/positions"
+)
+
+func TestToSAN(t *testing.T) {
+	const fen = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+	tests := []struct {
+		name  string
+		moves []string
+		want  []string
+	}{
+		{"legal line", []string{"e2e4", "e7e5", "g1f3", "b8c6", "f1b5"}, []string{"e4", "e5", "Nf3", "Nc6", "Bb5"}},
+		{"illegal move", []string{"e2e4", "e2e4", "g1f3"}, []string{"e4"}},
+		{"malformed move", []string{"e2"}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := toSAN(fen, tt.moves); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+	if got := toSAN("not a FEN", []string{"e2e4"}); got != nil {
+		t.Errorf("expected nil for an invalid FEN, got %v", got)
+	}
+}
+
+func TestNewEvaluation(t *testing.T) {
+	// 1. e4 - black to move, so the engine's score is from black's point of view
+	fen := positions.FEN("rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq")
+	result := &engine.Result{
+		Depth:    24,
+		Nodes:    1000,
+		Score:    engine.Score{Centipawns: -30},
+		WDL:      &engine.WDL{Win: 20, Draw: 900, Loss: 80},
+		PV:       []string{"c7c5", "g1f3"},
+		BestMove: "c7c5",
+	}
+	expected := positions.Evaluation{
+		Perspective: positions.WhitePerspective,
+		WhiteToMove: false,
+		Centipawns:  30,
+		Depth:       24,
+		Nodes:       1000,
+		WDL:         &positions.WDL{Win: 80, Draw: 900, Loss: 20},
+		BestMove:    "c5",
+		PV:          []string{"c5", "Nf3"},
+	}
+	if got := newEvaluation(fen, result); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+	if s := newEvaluation(fen, result).String(); s != "+0.30 (d24) [80/900/20]" {
+		t.Errorf("unexpected string representation: %q", s)
+	}
+
+	result = &engine.Result{Depth: 10, Score: engine.Score{Mate: true, MateIn: 3}, BestMove: "d7d5"}
+	if got := newEvaluation(fen, result); got.MateIn != -3 || got.BestMove != "d5" || got.String() != "#-3 (d10)" {
+		t.Errorf("expected black to mate in 3 after d5, got %+v", got)
+	}
+}
+
+func TestNewAlternatives(t *testing.T) {
+	fen := positions.FEN("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq")
+	result := &engine.Result{
+		Lines: []engine.Line{
+			{Depth: 20, Score: engine.Score{Centipawns: 30}, PV: []string{"e2e4", "e7e5"}},
+			{Depth: 20, Score: engine.Score{Centipawns: 25}, PV: []string{"d2d4"}},
+			{Depth: 19, Score: engine.Score{Centipawns: 20}},
+		},
+	}
+	alternatives := newAlternatives(fen, result)
+	if len(alternatives) != 2 {
+		t.Fatalf("expected 2 alternatives with moves, got %+v", alternatives)
+	}
+	if a := alternatives[1]; a.BestMove != "d4" || a.Centipawns != 25 || a.Depth != 20 {
+		t.Errorf("expected d4 +0.25 (d20), got %+v", a)
+	}
+	if a := alternatives[0]; !reflect.DeepEqual(a.PV, []string{"e4", "e5"}) {
+		t.Errorf("expected e4 e5, got %v", a.PV)
+	}
+}
@@ -0,0 +1,142 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/Hofsiedge/ChessOpeningAnalyzer/internal/positions"
+)
+
+// Classification grades a move by the evaluation it loses
+type Classification int
+
+const (
+	Good Classification = iota
+	Inaccuracy
+	Mistake
+	Blunder
+)
+
+// String implements fmt.Stringer interface
+func (c Classification) String() string {
+	switch c {
+	case Inaccuracy:
+		return "inaccuracy"
+	case Mistake:
+		return "mistake"
+	case Blunder:
+		return "blunder"
+	}
+	return "good"
+}
+
+// Thresholds are the minimal evaluation losses in centipawns for each classification
+type Thresholds struct {
+	Inaccuracy int
+	Mistake    int
+	Blunder    int
+}
+
+var DefaultThresholds = Thresholds{
+	Inaccuracy: 50,
+	Mistake:    100,
+	Blunder:    300,
+}
+
+// Classify grades an evaluation loss in centipawns
+func (t Thresholds) Classify(loss int) Classification {
+	switch {
+	case loss >= t.Blunder:
+		return Blunder
+	case loss >= t.Mistake:
+		return Mistake
+	case loss >= t.Inaccuracy:
+		return Inaccuracy
+	}
+	return Good
+}
+
+// maxEvaluation caps evaluations before computing losses,
+// so that e.g. missing a mate in a completely winning position does not count as a blunder
+const maxEvaluation = 1000
+
+// Weakness is a move of the user that loses a noticeable part of the evaluation
+type Weakness struct {
+	// Moves lead from the starting position to the position after the weak move, which is the last one
+	Moves []string
+	White bool
+	// Played is the number of games the move was played in
+	Played int
+	// Before and After are the evaluations of the positions before and after the move
+	Before positions.Evaluation
+	After  positions.Evaluation
+	// Loss is the evaluation loss in centipawns from the user's point of view
+	Loss           int
+	Classification Classification
+	// BestMove is the engine's preferred move in the position before the weak move
+	BestMove string
+}
+
+// String implements fmt.Stringer interface
+func (w Weakness) String() string {
+	best := ""
+	if w.BestMove != "" {
+		best = fmt.Sprintf(", engine prefers %v", w.BestMove)
+	}
+	return fmt.Sprintf("%v (-%.2f): %v - played %v times%v (%v -> %v)",
+		w.Classification, float64(w.Loss)/100, positions.FormatMoves(w.Moves), w.Played, best,
+		w.Before.WithPerspective(positions.WhitePerspective).Score(),
+		w.After.WithPerspective(positions.WhitePerspective).Score())
+}
+
+// FindWeaknesses walks every move the user played in the graph and returns the ones classified
+// at least as inaccuracies, the worst ones first. Moves between positions that have not been evaluated are skipped.
+// The moves of every position are checked once, transpositions are reported with the first move order found
+func FindWeaknesses(graph *positions.PositionGraph, thresholds Thresholds) []Weakness {
+	weaknesses := make([]Weakness, 0)
+	_ = graph.Walk(context.Background(), positions.Visitor{Pre: func(step positions.Step) error {
+		node, move := step.From, step.Move()
+		if node.Position.FEN.WhiteToMove() != step.White || !node.Position.Evaluated || !move.To.Position.Evaluated {
+			return nil
+		}
+		before, after := userValue(node.Position.Evaluation, step.White), userValue(move.To.Position.Evaluation, step.White)
+		loss := before - after
+		if classification := thresholds.Classify(loss); classification != Good {
+			moves := make([]string, len(step.Path))
+			for i, m := range step.Path {
+				moves[i] = m.Move
+			}
+			weaknesses = append(weaknesses, Weakness{
+				Moves:          moves,
+				White:          step.White,
+				Played:         move.Played,
+				Before:         node.Position.Evaluation,
+				After:          move.To.Position.Evaluation,
+				Loss:           loss,
+				Classification: classification,
+				BestMove:       node.Position.Evaluation.BestMove,
+			})
+		}
+		return nil
+	}}, positions.WalkOptions{Once: true})
+	sort.SliceStable(weaknesses, func(i, j int) bool {
+		return weaknesses[i].Loss > weaknesses[j].Loss
+	})
+	return weaknesses
+}
+
+// userValue returns a capped evaluation in centipawns from the user's point of view
+func userValue(e positions.Evaluation, white bool) int {
+	value := e.WithPerspective(positions.WhitePerspective).Value()
+	if !white {
+		value = -value
+	}
+	if value > maxEvaluation {
+		return maxEvaluation
+	}
+	if value < -maxEvaluation {
+		return -maxEvaluation
+	}
+	return value
+}
@@ -0,0 +1,112 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Hofsiedge/ChessOpeningAnalyzer/internal/positions"
+)
+
+func TestThresholds_Classify(t *testing.T) {
+	tests := map[int]Classification{
+		-20: Good,
+		49:  Good,
+		50:  Inaccuracy,
+		150: Mistake,
+		300: Blunder,
+	}
+	for loss, want := range tests {
+		if got := DefaultThresholds.Classify(loss); got != want {
+			t.Errorf("%v: expected %v, got %v", loss, want, got)
+		}
+	}
+}
+
+func TestFindWeaknesses(t *testing.T) {
+	graph := newTestGraph(t, "e4 e5 Nf3 Nc6", "e4 e5 Qh5 Nc6", "e4 e5 Qh5 g6", "e4 c5 Nf3 d6")
+	scores := map[string]int{
+		"e4":            30,
+		"e4 e5":         30,
+		"e4 e5 Nf3":     25,
+		"e4 e5 Qh5":     -60,
+		"e4 e5 Qh5 Nc6": -60,
+		"e4 e5 Qh5 g6":  500, // black's blunder is not the user's weakness
+		"e4 e5 Nf3 Nc6": 25,
+		"e4 c5":         35,
+		"e4 c5 Nf3":     -500,
+		"e4 c5 Nf3 d6":  -500,
+	}
+	evaluate := func(node *positions.PositionNode, variation string) {
+		if score, found := scores[variation]; found {
+			node.Position.Evaluated = true
+			node.Position.Evaluation = positions.Evaluation{
+				WhiteToMove: node.Position.FEN.WhiteToMove(),
+				Centipawns:  score,
+				BestMove:    map[string]string{"e4 e5": "Nf3", "e4 c5": "d4"}[variation],
+			}
+		}
+	}
+	var walk func(node *positions.PositionNode, variation string)
+	walk = func(node *positions.PositionNode, variation string) {
+		for _, move := range node.Moves {
+			next := move.Move
+			if variation != "" {
+				next = variation + " " + move.Move
+			}
+			evaluate(move.To, next)
+			walk(move.To, next)
+		}
+	}
+	walk(graph.WhitePositions, "")
+
+	weaknesses := FindWeaknesses(graph, DefaultThresholds)
+	if len(weaknesses) != 2 {
+		t.Fatalf("expected 2 weaknesses, got %v", weaknesses)
+	}
+	expected := Weakness{
+		Moves:          []string{"e4", "c5", "Nf3"},
+		White:          true,
+		Played:         1,
+		Before:         weaknesses[0].Before,
+		After:          weaknesses[0].After,
+		Loss:           535,
+		Classification: Blunder,
+		BestMove:       "d4",
+	}
+	if !reflect.DeepEqual(weaknesses[0], expected) {
+		t.Errorf("expected %+v, got %+v", expected, weaknesses[0])
+	}
+	if w := weaknesses[1]; w.Classification != Inaccuracy || w.Loss != 90 || w.Played != 2 ||
+		!reflect.DeepEqual(w.Moves, []string{"e4", "e5", "Qh5"}) {
+		t.Errorf("expected 3. Qh5 to be an inaccuracy played twice, got %+v", w)
+	}
+	if s := weaknesses[1].String(); s != "inaccuracy (-0.90): 1. e4 e5 2. Qh5 - played 2 times, engine prefers Nf3 (+0.30 -> -0.60)" {
+		t.Errorf("unexpected string representation: %q", s)
+	}
+
+	// the first move is checked once the starting position is evaluated
+	graph.WhitePositions.Position.Evaluated = true
+	graph.WhitePositions.Position.Evaluation = positions.Evaluation{WhiteToMove: true, Centipawns: 120, BestMove: "d4"}
+	weaknesses = FindWeaknesses(graph, DefaultThresholds)
+	if len(weaknesses) != 3 {
+		t.Fatalf("expected 3 weaknesses, got %v", weaknesses)
+	}
+	if w := weaknesses[1]; !reflect.DeepEqual(w.Moves, []string{"e4"}) || w.Loss != 90 || w.Played != 4 || w.BestMove != "d4" {
+		t.Errorf("expected 1. e4 to be an inaccuracy compared with d4, got %+v", w)
+	}
+}
+
+func TestFindWeaknesses_Transpositions(t *testing.T) {
+	graph := newTestGraph(t, "Nf3 Nf6 Ng1 Ng8 Nf3", "e4 e5 Nf3 Nc6", "Nf3 Nc6 e4 e5")
+	for _, node := range graph.WhitePositionIndex {
+		node.Position.Evaluated = true
+		node.Position.Evaluation = positions.Evaluation{WhiteToMove: node.Position.FEN.WhiteToMove()}
+	}
+	// 2. Ng1 loses a pawn, 3. Nf3 repeats the position after 1. Nf3
+	ng1 := graph.WhitePositions.Moves[0].To.Moves[0].To.Moves[0].To
+	ng1.Position.Evaluation.Centipawns = -100
+	weaknesses := FindWeaknesses(graph, DefaultThresholds)
+	if len(weaknesses) != 1 || positions.FormatMoves(weaknesses[0].Moves) != "1. Nf3 Nf6 2. Ng1" {
+		t.Errorf("expected a single weakness, got %v", weaknesses)
+	}
+}
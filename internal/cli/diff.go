@@ -0,0 +1,44 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/Hofsiedge/ChessOpeningAnalyzer/internal/positions"
+	"github.com/spf13/cobra"
+)
+
+var DiffOptionsFlag positions.DiffOptions
+
+func NewDiffCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "diff old_path new_path",
+		Short: "show how your repertoire changed between two position graphs",
+		Long: `show how your repertoire changed between two position graphs (e.g. of the last and of this quarter).
+lines that appeared are marked with +, lines that disappeared with -, and moves that are played
+much more or less often or after which your score changed with ~`,
+		Example: `$ openinganalyzer diff q1.out q2.out --frequency 0.3
+  Print out the lines added to and removed from q1.out in q2.out
+  and the moves whose share of games changed by 30 percentage points`,
+		ValidArgs: []string{"old_path", "new_path"},
+		Args:      cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			graphs := make([]*positions.PositionGraph, len(args))
+			for i, path := range args {
+				graph, err := positions.LoadGraph(path)
+				if err != nil {
+					return fmt.Errorf("could not load %v: %w", path, err)
+				}
+				graphs[i] = graph
+			}
+			_, err := fmt.Fprint(cmd.OutOrStdout(), positions.Diff(graphs[0], graphs[1], DiffOptionsFlag))
+			return err
+		},
+	}
+	cmd.Flags().Float64Var(&DiffOptionsFlag.Frequency, "frequency", positions.DefaultDiffOptions.Frequency,
+		"minimal change of the share of games a move is played in (0.2 = 20 percentage points)")
+	cmd.Flags().Float64Var(&DiffOptionsFlag.Score, "score", positions.DefaultDiffOptions.Score,
+		"minimal change of your score after a move (0.2 = 20 percentage points)")
+	cmd.Flags().IntVar(&DiffOptionsFlag.MinGames, "min-games", positions.DefaultDiffOptions.MinGames,
+		"minimal number of games with a known result in each graph to compare the scores")
+	return cmd
+}
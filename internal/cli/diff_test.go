@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/Hofsiedge/ChessOpeningAnalyzer/internal/fetching"
+	"github.com/Hofsiedge/ChessOpeningAnalyzer/internal/positions"
+)
+
+func TestDiff(t *testing.T) {
+	dir := t.TempDir()
+	paths := []string{filepath.Join(dir, "diff_old.bin"), filepath.Join(dir, "diff_new.bin")}
+	for i, games := range [][][]string{
+		{{"e4", "e5"}, {"e4", "e5"}, {"d4", "d5"}},
+		{{"e4", "e5"}, {"e4", "c5"}, {"d4", "d5"}},
+	} {
+		graph, _ := positions.NewPositionGraph(2)
+		for _, moves := range games {
+			if err := graph.AddGame(fetching.UserGame{White: true, Moves: moves, Result: fetching.Win}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := positions.DumpGraph(graph, paths[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmd := NewDiffCmd()
+	buffer := new(bytes.Buffer)
+	cmd.SetOut(buffer)
+	cmd.SetArgs(paths)
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	expected := `White positions:
+└─── e4
+      ├─── ~ e5 (played 100% -> 50%)
+      └─── + c5 [1 game, +1 =0 -0]
+`
+	if got := buffer.String(); got != expected {
+		t.Errorf("expected:\n%v\ngot:\n%v", expected, got)
+	}
+
+	buffer.Reset()
+	cmd.SetArgs(append(paths, "--frequency", "0.6"))
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	expected = `White positions:
+└─── e4
+      └─── + c5 [1 game, +1 =0 -0]
+`
+	if got := buffer.String(); got != expected {
+		t.Errorf("expected:\n%v\ngot:\n%v", expected, got)
+	}
+}
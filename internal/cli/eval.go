@@ -0,0 +1,219 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Hofsiedge/ChessOpeningAnalyzer/internal/analysis"
+	"github.com/Hofsiedge/ChessOpeningAnalyzer/internal/engine"
+	"github.com/Hofsiedge/ChessOpeningAnalyzer/internal/positions"
+	"github.com/spf13/cobra"
+)
+
+var (
+	EvalEngineFlag  string
+	EvalDepthFlag   int
+	EvalOutputFlag  string
+	EvalWorkersFlag int
+	EvalThreadsFlag int
+	EvalHashFlag    int
+	EvalForceFlag   bool
+	EvalMultiPVFlag int
+	EvalCacheFlag   string
+
+	EvalProfileFlag  string
+	EvalProfilesFlag string
+	EvalNodesFlag    int
+	EvalMoveTimeFlag time.Duration
+	EvalBudgetFlag   time.Duration
+
+	EvalCheckpointEveryFlag    int
+	EvalCheckpointIntervalFlag time.Duration
+)
+
+var (
+	ErrNoEngine          = errors.New("no engine specified")
+	ErrEngineError       = errors.New("engine error")
+	ErrForceWithoutDepth = errors.New("--force without a search depth")
+)
+
+func NewEvalCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "eval path (--engine engine_path | -p profile) [-d depth] [-w workers] [-o output]",
+		Short: "evaluate a position graph with a UCI engine",
+		Long: `evaluate every position of a position graph with a UCI engine (e.g. Stockfish).
+positions are shared between -w engine processes, each using --threads threads and --hash MB of hash.
+the evaluated graph is saved back to path unless -o is provided. the graph is also saved periodically,
+so an interrupted evaluation resumes from the last checkpoint when the command is run again.
+already evaluated positions are skipped unless --force is set and they were evaluated at a lower depth than --depth.
+--multipv stores the engine's best moves in every position (see print --alternatives).
+--cache keeps the results in a file shared between graphs, so that a position is not searched twice.
+-p takes the engine, its options and the search limit from a profile of the --profiles file,
+flags that are set explicitly override the profile. a profiles file looks like this:
+  {"deep": {"engine": "/usr/bin/stockfish", "options": {"Hash": "4096", "SyzygyPath": "/opt/syzygy"},
+            "depth": 30, "nodes": 0, "movetime": "", "budget": "2h"}}
+--budget limits the total time of the evaluation: it is split between the positions
+in proportion to how often they were reached in the games`,
+		Example: `$ openinganalyzer eval openings.out --engine /usr/bin/stockfish -d 20 -w 4 --threads 2
+  Evaluate every position of the graph stored in openings.out with 4 Stockfish
+  processes using 2 threads each at depth 20 and save the scores to openings.out
+
+  $ openinganalyzer eval openings.out -p deep --budget 30m
+  Evaluate the graph with the "deep" profile, spending 30 minutes in total`,
+		ValidArgs: []string{"path"},
+		Args:      cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			profile, err := evalProfile(cmd)
+			if err != nil {
+				return err
+			}
+			if profile.Engine == "" {
+				return fmt.Errorf("%w: use the --engine or --profile flag", ErrNoEngine)
+			}
+			if EvalForceFlag && profile.Limit.Depth == 0 {
+				return fmt.Errorf("%w: --force compares the depths of the evaluations, set --depth", ErrForceWithoutDepth)
+			}
+			path := args[0]
+			graph, err := positions.LoadGraph(path)
+			if err != nil {
+				return err
+			}
+			poolConfig := engine.PoolConfig{
+				Path:    profile.Engine,
+				Size:    EvalWorkersFlag,
+				MultiPV: EvalMultiPVFlag,
+				Options: profile.Options,
+			}
+			// without a profile the defaults of --threads and --hash are used as well
+			if EvalProfileFlag == "" || cmd.Flags().Changed("threads") {
+				poolConfig.Threads = EvalThreadsFlag
+			}
+			if EvalProfileFlag == "" || cmd.Flags().Changed("hash") {
+				poolConfig.Hash = EvalHashFlag
+			}
+			pool, err := engine.StartPool(cmd.Context(), poolConfig)
+			if err != nil {
+				return fmt.Errorf("%w: %w", ErrEngineError, err)
+			}
+			defer pool.Close()
+			cache := analysis.NewCache()
+			if EvalCacheFlag != "" {
+				if cache, err = analysis.LoadCache(EvalCacheFlag); err != nil {
+					return err
+				}
+			}
+			cachingEvaluator := &analysis.CachingEvaluator{
+				Evaluator:  pool,
+				Cache:      cache,
+				EngineName: pool.Name(),
+				MultiPV:    EvalMultiPVFlag,
+			}
+			output := EvalOutputFlag
+			if output == "" {
+				output = path
+			}
+			save := func() error {
+				if EvalCacheFlag != "" {
+					if err := cache.Dump(EvalCacheFlag); err != nil {
+						return err
+					}
+				}
+				return positions.DumpGraph(graph, output)
+			}
+			stats, evalErr := analysis.EvaluateGraph(cmd.Context(), graph, cachingEvaluator, analysis.EvaluateOptions{
+				Limit:   profile.Limit,
+				Workers: pool.Size(),
+				Force:   EvalForceFlag,
+				Budget:  profile.Budget,
+				Source:  pool.Name(),
+				Checkpoint: func(stats analysis.Stats) error {
+					if _, err := fmt.Fprintf(cmd.OutOrStdout(), "Checkpoint: evaluated %v\n", stats); err != nil {
+						return err
+					}
+					return save()
+				},
+				CheckpointEvery:    EvalCheckpointEveryFlag,
+				CheckpointInterval: EvalCheckpointIntervalFlag,
+			})
+			if evalErr != nil {
+				if _, err = fmt.Fprintf(cmd.OutOrStdout(), "Evaluation stopped, saving the progress to %v\n", output); err != nil {
+					return err
+				}
+				if err = save(); err != nil {
+					return err
+				}
+				return fmt.Errorf("%w: %w", ErrEngineError, evalErr)
+			}
+			if _, err = fmt.Fprintf(cmd.OutOrStdout(), "Evaluated %v with %v (%v from cache), skipped %v evaluated positions\n",
+				stats, pool.Name(), cachingEvaluator.Hits(), stats.Skipped); err != nil {
+				return err
+			}
+			if _, err = fmt.Fprintf(cmd.OutOrStdout(), "Dumping a position graph to %v\n", output); err != nil {
+				return err
+			}
+			if err = save(); err != nil {
+				return err
+			}
+			_, err = fmt.Fprintln(cmd.OutOrStdout(), "Successfully saved a position graph!")
+			return err
+		},
+	}
+	cmd.Flags().StringVarP(&EvalEngineFlag, "engine", "e", "", "path to a UCI engine executable")
+	cmd.Flags().StringVarP(&EvalProfileFlag, "profile", "p", "", "engine profile from the --profiles file")
+	cmd.Flags().StringVar(&EvalProfilesFlag, "profiles", defaultProfilesPath(), "engine profiles file")
+	cmd.Flags().IntVarP(&EvalDepthFlag, "depth", "d", 18, "search depth for every position")
+	cmd.Flags().IntVar(&EvalNodesFlag, "nodes", 0, "maximum number of nodes searched in every position")
+	cmd.Flags().DurationVar(&EvalMoveTimeFlag, "movetime", 0, "search time for every position")
+	cmd.Flags().DurationVar(&EvalBudgetFlag, "budget", 0, "total time of the evaluation, overrides --movetime")
+	cmd.Flags().IntVarP(&EvalWorkersFlag, "workers", "w", 1, "number of engine processes")
+	cmd.Flags().IntVar(&EvalThreadsFlag, "threads", 1, "Threads option of every engine process")
+	cmd.Flags().IntVar(&EvalHashFlag, "hash", 16, "Hash option (MB) of every engine process")
+	cmd.Flags().IntVar(&EvalMultiPVFlag, "multipv", 3, "number of the engine's best moves stored for every position")
+	cmd.Flags().BoolVarP(&EvalForceFlag, "force", "f", false, "re-evaluate positions evaluated at a lower depth than --depth")
+	cmd.Flags().IntVar(&EvalCheckpointEveryFlag, "checkpoint-every", 100, "save the graph every N evaluated positions")
+	cmd.Flags().DurationVar(&EvalCheckpointIntervalFlag, "checkpoint-interval", time.Minute, "save the graph at least this often")
+	cmd.Flags().StringVar(&EvalCacheFlag, "cache", "", "evaluation cache file (no cache by default)")
+	cmd.Flags().StringVarP(&EvalOutputFlag, "output", "o", "", "output file (defaults to the input file)")
+	return cmd
+}
+
+// evalProfile combines the profile chosen with --profile with the explicitly set flags.
+// The default --depth is used only if neither the profile nor the flags limit the search
+func evalProfile(cmd *cobra.Command) (engine.Profile, error) {
+	profile := engine.Profile{}
+	if EvalProfileFlag != "" {
+		var err error
+		if profile, err = engine.LoadProfile(EvalProfilesFlag, EvalProfileFlag); err != nil {
+			return profile, err
+		}
+	}
+	flags := cmd.Flags()
+	if profile.Engine == "" || flags.Changed("engine") {
+		profile.Engine = EvalEngineFlag
+	}
+	if flags.Changed("nodes") {
+		profile.Limit.Nodes = EvalNodesFlag
+	}
+	if flags.Changed("movetime") {
+		profile.Limit.MoveTime = EvalMoveTimeFlag
+	}
+	if flags.Changed("budget") {
+		profile.Budget = EvalBudgetFlag
+	}
+	if flags.Changed("depth") || (profile.Limit == engine.Limit{} && profile.Budget == 0) {
+		profile.Limit.Depth = EvalDepthFlag
+	}
+	return profile, nil
+}
+
+// defaultProfilesPath returns a path to the engine profiles in the user's config directory
+func defaultProfilesPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "openinganalyzer", "profiles.json")
+}
@@ -0,0 +1,174 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Hofsiedge/ChessOpeningAnalyzer/internal/engine"
+	"github.com/Hofsiedge/ChessOpeningAnalyzer/internal/fetching"
+	"github.com/Hofsiedge/ChessOpeningAnalyzer/internal/positions"
+)
+
+func TestEvalArguments(t *testing.T) {
+	graph, _ := positions.NewPositionGraph(2)
+	if err := graph.AddGame(fetching.UserGame{
+		White:   true,
+		EndTime: time.Date(2021, 7, 8, 0, 0, 0, 0, time.UTC),
+		Moves:   []string{"e4", "e5"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "eval_qux.bin")
+	if err := positions.DumpGraph(graph, path); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := NewEvalCmd()
+	cmd.SetOut(new(bytes.Buffer))
+	cmd.SetErr(io.Discard)
+	cmd.SetArgs([]string{path})
+	if err := cmd.Execute(); !errors.Is(err, ErrNoEngine) {
+		t.Errorf("expected \"%v\" error, got \"%v\"", ErrNoEngine, err)
+	}
+	cmd.SetArgs([]string{path, "--engine", "../../testdata/non-existent-engine"})
+	if err := cmd.Execute(); !errors.Is(err, ErrEngineError) {
+		t.Errorf("expected \"%v\" error, got \"%v\"", ErrEngineError, err)
+	}
+
+	cmd = NewEvalCmd()
+	cmd.SetOut(new(bytes.Buffer))
+	cmd.SetErr(io.Discard)
+	cmd.SetArgs([]string{path, "--engine", "../../testdata/non-existent-engine", "--movetime", "1s", "-f"})
+	if err := cmd.Execute(); !errors.Is(err, ErrForceWithoutDepth) {
+		t.Errorf("expected \"%v\" error, got \"%v\"", ErrForceWithoutDepth, err)
+	}
+}
+
+// buildFakeEngine compiles the scripted engine from internal/engine/fakeuci and points it at config
+func buildFakeEngine(t *testing.T, config string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "fakeuci")
+	if output, err := exec.Command("go", "build", "-o", path, "../engine/fakeuci").CombinedOutput(); err != nil {
+		t.Fatalf("could not build the fake engine: %v\n%s", err, output)
+	}
+	previous, set := os.LookupEnv("FAKEUCI_CONFIG")
+	if err := os.Setenv("FAKEUCI_CONFIG", config); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if set {
+			os.Setenv("FAKEUCI_CONFIG", previous)
+		} else {
+			os.Unsetenv("FAKEUCI_CONFIG")
+		}
+	})
+	return path
+}
+
+func TestEval_FakeEngine(t *testing.T) {
+	enginePath := buildFakeEngine(t, "../../testdata/engine/fakeuci.json")
+	graph, _ := positions.NewPositionGraph(3)
+	if err := graph.AddGame(fetching.UserGame{
+		White:   true,
+		EndTime: time.Date(2021, 7, 8, 0, 0, 0, 0, time.UTC),
+		Moves:   []string{"e4", "e5", "Qh5"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "eval_fake.bin")
+	if err := positions.DumpGraph(graph, path); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := NewEvalCmd()
+	buffer := new(bytes.Buffer)
+	cmd.SetOut(buffer)
+	cmd.SetArgs([]string{path, "--engine", enginePath, "-d", "12", "--multipv", "2",
+		"--cache", filepath.Join(t.TempDir(), "evaluations.cache")})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buffer.String(), "with FakeUCI 1.0 (0 from cache)") {
+		t.Errorf("unexpected output:\n%v", buffer.String())
+	}
+
+	graph, err := positions.LoadGraph(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e4 := graph.WhitePositions.Moves[0].To
+	if got := e4.Position.Evaluation; got.String() != "+0.30 (d12) [50/900/50]" || got.Source != "FakeUCI 1.0" {
+		t.Errorf("unexpected evaluation after e4: %v from %q", got, got.Source)
+	}
+	if len(e4.Alternatives) != 2 || e4.Alternatives[0].BestMove != "c5" || e4.Alternatives[1].BestMove != "e5" {
+		t.Errorf("unexpected alternatives after e4: %+v", e4.Alternatives)
+	}
+
+	weaknesses := NewWeaknessesCmd()
+	buffer.Reset()
+	weaknesses.SetOut(buffer)
+	weaknesses.SetArgs([]string{path})
+	if err = weaknesses.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	expected := "1. [white] inaccuracy (-0.55): 1. e4 e5 2. Qh5 - played 1 times, engine prefers Nf3 (+0.35 -> -0.20)\n"
+	if got := buffer.String(); got != expected {
+		t.Errorf("expected:\n%v\ngot:\n%v", expected, got)
+	}
+}
+
+func TestEval_Profile(t *testing.T) {
+	enginePath := buildFakeEngine(t, "../../testdata/engine/fakeuci.json")
+	graph, _ := positions.NewPositionGraph(2)
+	if err := graph.AddGame(fetching.UserGame{
+		White:   true,
+		EndTime: time.Date(2021, 7, 8, 0, 0, 0, 0, time.UTC),
+		Moves:   []string{"e4", "e5"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "eval_profile.bin")
+	if err := positions.DumpGraph(graph, path); err != nil {
+		t.Fatal(err)
+	}
+	profiles := filepath.Join(t.TempDir(), "profiles.json")
+	config := fmt.Sprintf(`{"quick": {"engine": %q, "options": {"Hash": "32"}, "depth": 8}}`, enginePath)
+	if err := os.WriteFile(profiles, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := NewEvalCmd()
+	cmd.SetOut(new(bytes.Buffer))
+	cmd.SetErr(io.Discard)
+	cmd.SetArgs([]string{path, "-p", "unknown", "--profiles", profiles})
+	if err := cmd.Execute(); !errors.Is(err, engine.ErrUnknownProfile) {
+		t.Errorf("expected \"%v\" error, got \"%v\"", engine.ErrUnknownProfile, err)
+	}
+	cmd.SetArgs([]string{path, "-p", "quick", "--profiles", profiles})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if graph, err := positions.LoadGraph(path); err != nil {
+		t.Fatal(err)
+	} else if e := graph.WhitePositions.Moves[0].To.Position.Evaluation; e.Depth != 8 {
+		t.Errorf("expected the depth of the profile, got %v", e)
+	}
+
+	cmd.SetArgs([]string{path, "-p", "quick", "--profiles", profiles, "-d", "10", "-f"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if graph, err := positions.LoadGraph(path); err != nil {
+		t.Fatal(err)
+	} else if e := graph.WhitePositions.Moves[0].To.Position.Evaluation; e.Depth != 10 {
+		t.Errorf("expected --depth to override the profile, got %v", e)
+	}
+}
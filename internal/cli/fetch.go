@@ -1,9 +1,11 @@
 package cli
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/url"
+	"runtime"
 	"time"
 
 	"github.com/Hofsiedge/ChessOpeningAnalyzer/internal/fetching"
@@ -14,8 +16,9 @@ import (
 )
 
 var (
-	FetchOutputFlag string
-	MoveCapFlag     int
+	FetchOutputFlag  string
+	MoveCapFlag      int
+	FetchWorkersFlag int
 )
 
 var (
@@ -35,7 +38,10 @@ func NewFetchCommand(cfg FetchCmdConfig) *cobra.Command {
 		SuggestFor: []string{"etch", "ftch", "fech", "fetc", "feth", "get", "download"},
 		Short:      "fetch your games from an online chess platform",
 		Long: `fetch your games from an online chess platform (chesscom/lichess).
-dates are specified in YYYY-MM-DD format. optionally accepts number of moves as -m flag`,
+dates are specified in YYYY-MM-DD format. optionally accepts number of moves as -m flag.
+the server-side analysis of lichess games is imported, so eval skips the positions lichess has evaluated.
+games are downloaded one request at a time and added to the position graph as they arrive,
+by several goroutines at once (-w)`,
 		ValidArgs: []string{"platform", "username", "start_date", "end_date"},
 		Example: `$ openinganalyzer fetch chesscom YourUsername 2021-10-01 2021-12-31 -m 5
   Fetch from chess.com, username - YourUsername, start_date - 01.10.2021,
@@ -43,7 +49,7 @@ dates are specified in YYYY-MM-DD format. optionally accepts number of moves as
 		Args: cobra.ExactArgs(4),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			platform := args[0]
-			var fetcher fetching.GameFetcher
+			var fetcher fetching.GameStreamer
 			switch platform {
 			case "chesscom":
 				fetcher = &chesscom.Fetcher{
@@ -66,16 +72,34 @@ dates are specified in YYYY-MM-DD format. optionally accepts number of moves as
 				}
 			}
 			filter.NumberOfMovesCap = MoveCapFlag
-			var games []*fetching.UserGame
-			if games, err = fetcher.Fetch(username, filter, 1); err != nil {
+			// games are added to the graph as they arrive, the stream is abandoned after a fetching error
+			ctx, cancel := context.WithCancel(cmd.Context())
+			defer cancel()
+			invalidGames := 0
+			workers := FetchWorkersFlag
+			if workers < 1 {
+				workers = runtime.NumCPU()
+			}
+			// -w only parallelizes building the graph: chess.com rate limits parallel requests
+			// and lichess sends all the games in a single response, so the games are fetched by one worker
+			stream := fetcher.Stream(ctx, username, filter, 1)
+			graph, _, err := positions.BuildGraph(MoveCapFlag, workers, stream, func(err error) error {
+				switch {
+				case errors.Is(err, lichess.ErrInvalidPGNTags):
+					invalidGames++
+					return nil
+				case errors.Is(err, positions.ErrInvalidGame):
+					_, err = fmt.Fprintf(cmd.OutOrStdout(), "Error adding a game to the graph: %v\n", err)
+					return err
+				}
 				return fmt.Errorf("%w: %w", ErrFetchingError, err)
+			})
+			if err != nil {
+				return err
 			}
-			graph, _ := positions.NewPositionGraph(MoveCapFlag)
-			for _, game := range games {
-				if err = graph.AddGame(*game); err != nil {
-					if _, oerr := fmt.Fprintf(cmd.OutOrStdout(), "Error adding a game to the graph: %v\n", err); oerr != nil {
-						return oerr
-					}
+			if invalidGames != 0 {
+				if _, err := fmt.Fprintf(cmd.OutOrStdout(), "Skipped %d games with invalid PGN tags\n", invalidGames); err != nil {
+					return err
 				}
 			}
 			if _, err := fmt.Fprintf(cmd.OutOrStdout(), "Dumping a position graph to %v\n", FetchOutputFlag); err != nil {
@@ -90,5 +114,6 @@ dates are specified in YYYY-MM-DD format. optionally accepts number of moves as
 	}
 	cmd.Flags().StringVarP(&FetchOutputFlag, "output", "o", "openings.out", "output file")
 	cmd.Flags().IntVarP(&MoveCapFlag, "moves", "m", 5, "how deep you want a position graph to be")
+	cmd.Flags().IntVarP(&FetchWorkersFlag, "workers", "w", 0, "number of goroutines building the graph, all the CPUs by default")
 	return cmd
 }
@@ -3,11 +3,13 @@ package cli
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -45,15 +47,16 @@ func TestFetchCommand(t *testing.T) {
 	buffer := new(bytes.Buffer)
 	cmd.SetOut(buffer)
 	cmd.SetErr(io.Discard)
-	cmd.SetArgs([]string{"chesscom", "Hofsiedge", "2021-07-01", "2021-07-10", "-o", "../../testdata/test_qux.bin", "-m", "3"})
+	path := filepath.Join(t.TempDir(), "test_qux.bin")
+	cmd.SetArgs([]string{"chesscom", "Hofsiedge", "2021-07-01", "2021-07-10", "-o", path, "-m", "3", "-w", "4"})
 	if err := cmd.Execute(); err != nil {
 		t.Error(err)
 		return
 	}
 	logs := buffer.String()
-	if logs != `Dumping a position graph to ../../testdata/test_qux.bin
+	if logs != fmt.Sprintf(`Dumping a position graph to %v
 Successfully saved a position graph!
-` {
+`, path) {
 		t.Errorf("Output of fetch cmd doesn't match expected format - got:\n%v", buffer.String())
 	}
 
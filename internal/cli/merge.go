@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/Hofsiedge/ChessOpeningAnalyzer/internal/positions"
+	"github.com/spf13/cobra"
+)
+
+var MergeOutputFlag string
+
+func NewMergeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "merge path path... [-o output]",
+		Short: "merge several position graphs into one",
+		Long: `merge several position graphs (e.g. fetched from different platforms or months) into one.
+the numbers of games and results of the same moves are added up, positions evaluated in several graphs
+keep the deepest evaluation. the merged graph is as deep as the deepest of the graphs.
+different depths and evaluations made by different engines are reported as conflicts`,
+		Example: `$ openinganalyzer merge chesscom.out lichess.out -o all.out
+  Merge the position graphs stored in chesscom.out and lichess.out into all.out`,
+		Args: cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			graphs := make([]*positions.PositionGraph, len(args))
+			for i, path := range args {
+				graph, err := positions.LoadGraph(path)
+				if err != nil {
+					return fmt.Errorf("could not load %v: %w", path, err)
+				}
+				graphs[i] = graph
+			}
+			merged, conflicts, err := positions.Merge(graphs...)
+			if err != nil {
+				return err
+			}
+			for _, conflict := range conflicts {
+				if _, err = fmt.Fprintf(cmd.OutOrStdout(), "Conflict in %v: %v\n", args[conflict.Graph], conflict); err != nil {
+					return err
+				}
+			}
+			if _, err = fmt.Fprintf(cmd.OutOrStdout(), "Dumping the merged position graph to %v\n", MergeOutputFlag); err != nil {
+				return err
+			}
+			if err = positions.DumpGraph(merged, MergeOutputFlag); err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(cmd.OutOrStdout(), "Successfully merged %v position graphs, conflicts: %v\n", len(graphs), len(conflicts))
+			return err
+		},
+	}
+	cmd.Flags().StringVarP(&MergeOutputFlag, "output", "o", "openings.out", "output file")
+	return cmd
+}
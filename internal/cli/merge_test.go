@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Hofsiedge/ChessOpeningAnalyzer/internal/fetching"
+	"github.com/Hofsiedge/ChessOpeningAnalyzer/internal/positions"
+)
+
+func TestMerge(t *testing.T) {
+	dir := t.TempDir()
+	paths := []string{filepath.Join(dir, "merge_chesscom.bin"), filepath.Join(dir, "merge_lichess.bin")}
+	for i, moves := range [][]string{{"e4", "e5"}, {"e4", "c5", "Nf3"}} {
+		graph, _ := positions.NewPositionGraph(len(moves))
+		if err := graph.AddGame(fetching.UserGame{
+			White:   true,
+			EndTime: time.Date(2021, 7, 8, 0, 0, 0, 0, time.UTC),
+			Moves:   moves,
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if err := positions.DumpGraph(graph, paths[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	output := filepath.Join(dir, "merge_all.bin")
+
+	cmd := NewMergeCmd()
+	buffer := new(bytes.Buffer)
+	cmd.SetOut(buffer)
+	cmd.SetErr(io.Discard)
+	cmd.SetArgs(append(paths, "-o", output))
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	expected := fmt.Sprintf(`Conflict in %v: depth 3 differs from 2, the merged graph has depth 3
+Dumping the merged position graph to %v
+Successfully merged 2 position graphs, conflicts: 1
+`, paths[1], output)
+	if got := buffer.String(); got != expected {
+		t.Errorf("expected:\n%v\ngot:\n%v", expected, got)
+	}
+
+	merged, err := positions.LoadGraph(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e4 := merged.WhitePositions.Moves[0]; e4.Played != 2 || len(e4.To.Moves) != 2 {
+		t.Errorf("expected both games to be merged, got %+v", e4)
+	}
+
+	cmd.SetArgs([]string{paths[0], filepath.Join(dir, "missing.bin")})
+	if err := cmd.Execute(); err == nil {
+		t.Error("expected an error merging a missing graph")
+	}
+}
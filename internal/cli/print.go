@@ -1,12 +1,33 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"strings"
+	"time"
+
 	"github.com/Hofsiedge/ChessOpeningAnalyzer/internal/positions"
 	"github.com/spf13/cobra"
 )
 
-var PrintDateFlag bool
+var (
+	PrintDateFlag         bool
+	PrintAlternativesFlag bool
+	PrintStatsFlag        bool
+	PrintSinceFlag        string
+	PrintUntilFlag        string
+	PrintFromFlag         string
+	PrintFENFlag          string
+	PrintColorFlag        string
+	PrintMaxDepthFlag     int
+)
+
+var (
+	ErrInvalidColor     = errors.New("invalid color")
+	ErrPositionNotFound = errors.New("position not found")
+	ErrInvalidFEN       = errors.New("invalid FEN")
+)
 
 func NewPrintCmd() *cobra.Command {
 	cmd := &cobra.Command{
@@ -14,19 +35,129 @@ func NewPrintCmd() *cobra.Command {
 		Short: "print a position graph",
 		Example: `$ openinganalyzer print openings.out -d
   Print out a move tree of the position graph stored in openings.out
-  with dates next to leaf-moves`,
+  with the dates of the first and the last games next to every move
+
+  $ openinganalyzer print openings.out -s
+  Print out the number of games and the results after each move: e4 [12 games, +5 =3 -4]
+
+  $ openinganalyzer print openings.out --since 2021-01-01 --until 2021-06-30
+  Print out only the lines played in the first half of 2021
+
+  $ openinganalyzer print openings.out --from "1. e4 c5" --color white --max-depth 4
+  Print out 4 half-moves of your Sicilian lines as white`,
 		ValidArgs: []string{"path"},
 		Args:      cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			path := args[0]
+			opts := positions.PrintOptions{
+				Dates:        PrintDateFlag,
+				Alternatives: PrintAlternativesFlag,
+				Stats:        PrintStatsFlag,
+				MaxDepth:     PrintMaxDepthFlag,
+			}
+			for _, date := range []struct {
+				flag  string
+				value string
+				dest  *time.Time
+			}{{"since", PrintSinceFlag, &opts.Since}, {"until", PrintUntilFlag, &opts.Until}} {
+				if date.value == "" {
+					continue
+				}
+				parsed, err := time.Parse("2006-01-02", date.value)
+				if err != nil {
+					return fmt.Errorf("%w (--%v): %w", ErrInvalidDate, date.flag, err)
+				}
+				*date.dest = parsed
+			}
+			if !opts.Until.IsZero() {
+				// the whole day is included
+				opts.Until = opts.Until.Add(24*time.Hour - time.Nanosecond)
+			}
 			graph, err := positions.LoadGraph(path)
 			if err != nil {
 				return err
 			}
-			_, err = fmt.Fprint(cmd.OutOrStdout(), graph.Print(PrintDateFlag))
-			return err
+			if PrintFromFlag == "" && PrintFENFlag == "" && PrintColorFlag == "" {
+				_, err = fmt.Fprint(cmd.OutOrStdout(), graph.Print(opts))
+				return err
+			}
+			return printSubtrees(cmd.OutOrStdout(), graph, opts)
 		},
 	}
-	cmd.Flags().BoolVarP(&PrintDateFlag, "dates", "d", false, "print out the last date for each position")
+	cmd.Flags().BoolVarP(&PrintDateFlag, "dates", "d", false, "print out the first and the last dates for each move")
+	cmd.Flags().BoolVarP(&PrintAlternativesFlag, "alternatives", "a", false,
+		"print out the engine's moves that are better than the played ones (see eval --multipv)")
+	cmd.Flags().BoolVarP(&PrintStatsFlag, "stats", "s", false,
+		"print out the number of games and your wins, draws and losses after each move")
+	cmd.Flags().StringVar(&PrintSinceFlag, "since", "", "hide the lines not played since the date (YYYY-MM-DD)")
+	cmd.Flags().StringVar(&PrintUntilFlag, "until", "", "hide the lines not played until the date (YYYY-MM-DD)")
+	cmd.Flags().StringVar(&PrintFromFlag, "from", "", "start the tree after the moves (e.g. \"e4 c5 Nf3\")")
+	cmd.Flags().StringVar(&PrintFENFlag, "fen", "", "start the tree at the position")
+	cmd.Flags().StringVar(&PrintColorFlag, "color", "", "print out only your white or black positions")
+	cmd.Flags().IntVar(&PrintMaxDepthFlag, "max-depth", 0, "number of half-moves to print below the start, 0 to print all of them")
+	cmd.MarkFlagsMutuallyExclusive("from", "fen")
 	return cmd
 }
+
+// printSubtrees prints the trees of the repertoires selected by --color starting at --from or --fen
+func printSubtrees(out io.Writer, graph *positions.PositionGraph, opts positions.PrintOptions) error {
+	colors, err := parseColor(PrintColorFlag)
+	if err != nil {
+		return err
+	}
+	moves := positions.SplitMoves(PrintFromFlag)
+	fen := positions.TruncateFEN(PrintFENFlag)
+	var key positions.Key
+	if PrintFENFlag != "" {
+		if key, err = positions.FEN(PrintFENFlag).Key(); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidFEN, err)
+		}
+	}
+	lines := []string{"Position graph.", fmt.Sprintf("Depth: %v", graph.Depth)}
+	for _, white := range colors {
+		var node *positions.PositionNode
+		start := ""
+		switch {
+		case PrintFromFlag != "":
+			node = graph.FindLine(white, moves)
+			start = " after " + positions.FormatMoves(moves)
+		case PrintFENFlag != "":
+			node = graph.FindPosition(white, key)
+			start = fmt.Sprintf(" from %v", fen)
+		case white:
+			node = graph.WhitePositions
+		default:
+			node = graph.BlackPositions
+		}
+		if node == nil {
+			continue
+		}
+		tree := node.Print(opts)
+		if tree == "" && start == "" {
+			continue
+		}
+		color := "Black"
+		if white {
+			color = "White"
+		}
+		lines = append(lines, fmt.Sprintf("%v positions%v:\n%v", color, start, tree))
+	}
+	if len(lines) == 2 && (PrintFromFlag != "" || PrintFENFlag != "") {
+		return fmt.Errorf("%w: %v%v", ErrPositionNotFound, PrintFromFlag, PrintFENFlag)
+	}
+	_, err = fmt.Fprint(out, strings.Join(lines, "\n"))
+	return err
+}
+
+// parseColor returns the repertoires selected by a --color flag: white is true, black is false
+func parseColor(color string) ([]bool, error) {
+	switch color {
+	case "":
+		return []bool{true, false}, nil
+	case "white":
+		return []bool{true}, nil
+	case "black":
+		return []bool{false}, nil
+	}
+	return nil, fmt.Errorf("%w: %v. Only white and black are supported", ErrInvalidColor, color)
+}
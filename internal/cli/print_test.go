@@ -2,10 +2,12 @@ package cli
 
 import (
 	"bytes"
+	"errors"
 	"github.com/Hofsiedge/ChessOpeningAnalyzer/internal/fetching"
 	"github.com/Hofsiedge/ChessOpeningAnalyzer/internal/positions"
 	"io"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -32,7 +34,7 @@ func TestPrint(t *testing.T) {
 			return
 		}
 	}
-	path := "../../testdata/cli/qux.bin"
+	path := filepath.Join(t.TempDir(), "qux.bin")
 	// wd, _ := os.Getwd()
 	// fmt.Println(wd)
 	if err := positions.DumpGraph(graph, path); err != nil {
@@ -59,3 +61,128 @@ func TestPrint(t *testing.T) {
 		t.Errorf("results do not match")
 	}
 }
+
+func TestPrint_Window(t *testing.T) {
+	graph, _ := positions.NewPositionGraph(2)
+	for _, game := range []fetching.UserGame{{
+		White:   true,
+		EndTime: time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC),
+		Moves:   []string{"e4", "c5"},
+	}, {
+		White:   true,
+		EndTime: time.Date(2021, 7, 8, 0, 0, 0, 0, time.UTC),
+		Moves:   []string{"e4", "e5"},
+	}} {
+		if err := graph.AddGame(game); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := filepath.Join(t.TempDir(), "print_window.bin")
+	if err := positions.DumpGraph(graph, path); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := NewPrintCmd()
+	buffer := new(bytes.Buffer)
+	cmd.SetOut(buffer)
+	cmd.SetErr(io.Discard)
+	cmd.SetArgs([]string{path, "-d", "--since", "2021-06-01", "--until", "2021-07-08"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	expected := `Position graph.
+Depth: 2
+White positions:
+└─── e4 (01.03.2021 - 08.07.2021)
+      └─── e5 (08.07.2021)
+`
+	if got := buffer.String(); got != expected {
+		t.Errorf("expected:\n%v\ngot:\n%v", expected, got)
+	}
+
+	cmd.SetArgs([]string{path, "--since", "01.06.2021"})
+	if err := cmd.Execute(); !errors.Is(err, ErrInvalidDate) {
+		t.Errorf("expected \"%v\" error, got \"%v\"", ErrInvalidDate, err)
+	}
+}
+
+func TestPrint_Subtree(t *testing.T) {
+	graph, _ := positions.NewPositionGraph(4)
+	for _, game := range []fetching.UserGame{
+		{White: true, Moves: []string{"e4", "c5", "Nf3", "d6"}},
+		{White: true, Moves: []string{"e4", "c5", "c3", "Nf6"}},
+		{White: true, Moves: []string{"e4", "e5", "Nf3", "Nc6"}},
+		{White: false, Moves: []string{"e4", "c5", "Nf3", "Nc6"}},
+	} {
+		if err := graph.AddGame(game); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := filepath.Join(t.TempDir(), "print_subtree.bin")
+	if err := positions.DumpGraph(graph, path); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+		err  error
+	}{{
+		name: "From",
+		args: []string{"--from", "1. e4 c5"},
+		want: `Position graph.
+Depth: 4
+White positions after 1. e4 c5:
+├─── Nf3
+│     └─── d6
+└─── c3
+      └─── Nf6
+
+Black positions after 1. e4 c5:
+└─── Nf3
+      └─── Nc6
+`,
+	}, {
+		name: "FEN",
+		args: []string{"--fen", "rnbqkbnr/pp1ppppp/8/2p5/4P3/5N2/PPPP1PPP/RNBQKB1R b KQkq - 1 2", "--color", "black"},
+		want: `Position graph.
+Depth: 4
+Black positions from rnbqkbnr/pp1ppppp/8/2p5/4P3/5N2/PPPP1PPP/RNBQKB1R b KQkq:
+└─── Nc6
+`,
+	}, {
+		name: "ColorMaxDepth",
+		args: []string{"--color", "white", "--max-depth", "2"},
+		want: `Position graph.
+Depth: 4
+White positions:
+└─── e4
+      ├─── c5
+      └─── e5
+`,
+	}, {
+		name: "NotFound",
+		args: []string{"--from", "d4", "--color", "white"},
+		err:  ErrPositionNotFound,
+	}, {
+		name: "InvalidColor",
+		args: []string{"--color", "red"},
+		err:  ErrInvalidColor,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewPrintCmd()
+			buffer := new(bytes.Buffer)
+			cmd.SetOut(buffer)
+			cmd.SetErr(io.Discard)
+			cmd.SetArgs(append([]string{path}, tt.args...))
+			if err := cmd.Execute(); !errors.Is(err, tt.err) {
+				t.Fatalf("expected \"%v\" error, got \"%v\"", tt.err, err)
+			}
+			if got := buffer.String(); tt.err == nil && got != tt.want {
+				t.Errorf("expected:\n%v\ngot:\n%v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Hofsiedge/ChessOpeningAnalyzer/internal/positions"
+	"github.com/spf13/cobra"
+)
+
+var (
+	PruneDepthFlag     int
+	PruneMinPlayedFlag int
+	PruneSinceFlag     string
+	PruneOutputFlag    string
+)
+
+func NewPruneCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "prune path [--depth depth] [--min-played games] [--since date]",
+		Short: "remove rare, old or deep lines from a position graph",
+		Long: `remove lines from a position graph without fetching the games again.
+the graph can be cut down to a smaller depth (in half-moves), moves played in fewer games than --min-played
+and lines not played since the date (YYYY-MM-DD) are dropped`,
+		Example: `$ openinganalyzer prune openings.out --depth 6 --min-played 3 -o pruned.out
+  Keep the first 6 half-moves of the lines of openings.out played in at least 3 games
+  and save the graph to pruned.out`,
+		ValidArgs: []string{"path"},
+		Args:      cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts := positions.PruneOptions{
+				Depth:     PruneDepthFlag,
+				MinPlayed: PruneMinPlayedFlag,
+			}
+			if PruneSinceFlag != "" {
+				since, err := time.Parse("2006-01-02", PruneSinceFlag)
+				if err != nil {
+					return fmt.Errorf("%w (--since): %w", ErrInvalidDate, err)
+				}
+				opts.Since = since
+			}
+			path := args[0]
+			graph, err := positions.LoadGraph(path)
+			if err != nil {
+				return err
+			}
+			removed, err := graph.Prune(opts)
+			if err != nil {
+				return err
+			}
+			output := PruneOutputFlag
+			if output == "" {
+				output = path
+			}
+			if _, err = fmt.Fprintf(cmd.OutOrStdout(), "Removed %v positions, dumping a position graph to %v\n", removed, output); err != nil {
+				return err
+			}
+			if err = positions.DumpGraph(graph, output); err != nil {
+				return err
+			}
+			_, err = fmt.Fprintln(cmd.OutOrStdout(), "Successfully saved a position graph!")
+			return err
+		},
+	}
+	cmd.Flags().IntVar(&PruneDepthFlag, "depth", 0, "number of half-moves to keep, 0 keeps the depth of the graph")
+	cmd.Flags().IntVar(&PruneMinPlayedFlag, "min-played", 0, "remove the moves played in fewer games")
+	cmd.Flags().StringVar(&PruneSinceFlag, "since", "", "remove the lines not played since the date (YYYY-MM-DD)")
+	cmd.Flags().StringVarP(&PruneOutputFlag, "output", "o", "", "output file (defaults to the input file)")
+	return cmd
+}
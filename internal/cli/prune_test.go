@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Hofsiedge/ChessOpeningAnalyzer/internal/fetching"
+	"github.com/Hofsiedge/ChessOpeningAnalyzer/internal/positions"
+)
+
+func TestPrune(t *testing.T) {
+	graph, _ := positions.NewPositionGraph(3)
+	for _, moves := range [][]string{{"e4", "e5", "Nf3"}, {"e4", "e5", "Nc3"}, {"d4", "d5", "c4"}} {
+		if err := graph.AddGame(fetching.UserGame{
+			White:   true,
+			EndTime: time.Date(2021, 7, 8, 0, 0, 0, 0, time.UTC),
+			Moves:   moves,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := filepath.Join(t.TempDir(), "prune.bin")
+	if err := positions.DumpGraph(graph, path); err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(t.TempDir(), "prune_output.bin")
+
+	cmd := NewPruneCmd()
+	buffer := new(bytes.Buffer)
+	cmd.SetOut(buffer)
+	cmd.SetErr(io.Discard)
+	cmd.SetArgs([]string{path, "--depth", "2", "--min-played", "2", "-o", output})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	expected := fmt.Sprintf(`Removed 5 positions, dumping a position graph to %v
+Successfully saved a position graph!
+`, output)
+	if got := buffer.String(); got != expected {
+		t.Errorf("expected:\n%v\ngot:\n%v", expected, got)
+	}
+	pruned, err := positions.LoadGraph(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pruned.Depth != 2 || len(pruned.WhitePositionIndex) != 2 {
+		t.Errorf("expected 2 positions at depth 2, got %v at depth %v", len(pruned.WhitePositionIndex), pruned.Depth)
+	}
+
+	cmd.SetArgs([]string{path, "--since", "08.07.2021"})
+	if err := cmd.Execute(); !errors.Is(err, ErrInvalidDate) {
+		t.Errorf("expected \"%v\" error, got \"%v\"", ErrInvalidDate, err)
+	}
+	cmd.SetArgs([]string{path, "--depth", "4"})
+	if err := cmd.Execute(); err == nil {
+		t.Error("expected an error pruning to a larger depth")
+	}
+}
@@ -1,8 +1,10 @@
 package cli
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
 
 	"github.com/Hofsiedge/ChessOpeningAnalyzer/internal/fetching/chesscom"
 	"github.com/Hofsiedge/ChessOpeningAnalyzer/internal/fetching/lichess"
@@ -25,7 +27,11 @@ information on what are your weak moves in terms of precision, not just won/draw
 }
 
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	// commands stop gracefully on Ctrl-C through cmd.Context()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	err := rootCmd.ExecuteContext(ctx)
+	stop()
+	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
@@ -43,4 +49,22 @@ func init() {
 	// print
 	printCmd := NewPrintCmd()
 	rootCmd.AddCommand(printCmd)
+	// eval
+	evalCmd := NewEvalCmd()
+	rootCmd.AddCommand(evalCmd)
+	// weaknesses
+	weaknessesCmd := NewWeaknessesCmd()
+	rootCmd.AddCommand(weaknessesCmd)
+	// merge
+	mergeCmd := NewMergeCmd()
+	rootCmd.AddCommand(mergeCmd)
+	// diff
+	diffCmd := NewDiffCmd()
+	rootCmd.AddCommand(diffCmd)
+	// prune
+	pruneCmd := NewPruneCmd()
+	rootCmd.AddCommand(pruneCmd)
+	// search
+	searchCmd := NewSearchCmd()
+	rootCmd.AddCommand(searchCmd)
 }
@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Hofsiedge/ChessOpeningAnalyzer/internal/positions"
+	"github.com/spf13/cobra"
+)
+
+var SearchColorFlag string
+
+func NewSearchCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "search path position",
+		Short: "list the move orders that reach a position",
+		Long: `list every move order of a position graph that reaches a position, with the number of games and the dates.
+the position is given as a FEN or as moves played from the starting position.
+a position reached by several move orders makes the counts and the dates approximate`,
+		Example: `$ openinganalyzer search openings.out "1. Nf3 Nc6 2. e4 e5"
+  Print out how you reach the position after 1. Nf3 Nc6 2. e4 e5 (e.g. 1. e4 e5 2. Nf3 Nc6)
+
+  $ openinganalyzer search openings.out "r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq" --color black
+  Search for a position given as a FEN among your games as black`,
+		ValidArgs: []string{"path", "position"},
+		Args:      cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			colors, err := parseColor(SearchColorFlag)
+			if err != nil {
+				return err
+			}
+			// a FEN or moves played from the starting position
+			position := &positions.Position{FEN: positions.TruncateFEN(args[1])}
+			if strings.Contains(args[1], "/") {
+				if position.Key, err = positions.FEN(args[1]).Key(); err != nil {
+					return fmt.Errorf("%w: %w", ErrInvalidFEN, err)
+				}
+			} else if position, err = positions.LinePosition(positions.SplitMoves(args[1])); err != nil {
+				return err
+			}
+			graph, err := positions.LoadGraph(args[0])
+			if err != nil {
+				return err
+			}
+			lines := []string{fmt.Sprintf("Position: %v", position.FEN)}
+			for _, white := range colors {
+				paths := graph.Search(white, position.Key)
+				if len(paths) == 0 {
+					continue
+				}
+				color := "Black"
+				if white {
+					color = "White"
+				}
+				lines = append(lines, fmt.Sprintf("%v positions:", color))
+				for _, path := range paths {
+					lines = append(lines, path.String())
+				}
+			}
+			if len(lines) == 1 {
+				return fmt.Errorf("%w: %v", ErrPositionNotFound, position.FEN)
+			}
+			_, err = fmt.Fprintln(cmd.OutOrStdout(), strings.Join(lines, "\n"))
+			return err
+		},
+	}
+	cmd.Flags().StringVar(&SearchColorFlag, "color", "", "search only your white or black positions")
+	return cmd
+}
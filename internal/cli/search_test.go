@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Hofsiedge/ChessOpeningAnalyzer/internal/fetching"
+	"github.com/Hofsiedge/ChessOpeningAnalyzer/internal/positions"
+)
+
+func TestSearch(t *testing.T) {
+	graph, _ := positions.NewPositionGraph(4)
+	for _, game := range []struct {
+		white bool
+		moves string
+	}{
+		{true, "e4 e5 Nf3 Nc6"},
+		{true, "e4 e5 Nf3 Nc6"},
+		{true, "Nf3 Nc6 e4 e5"},
+		{false, "e4 e5 Nf3 Nc6"},
+	} {
+		if err := graph.AddGame(fetching.UserGame{
+			White:   game.white,
+			EndTime: time.Date(2021, 7, 8, 0, 0, 0, 0, time.UTC),
+			Moves:   strings.Split(game.moves, " "),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := filepath.Join(t.TempDir(), "search.bin")
+	if err := positions.DumpGraph(graph, path); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+		err  error
+	}{{
+		name: "Moves",
+		args: []string{"1. Nf3 Nc6 2. e4 e5"},
+		want: `Position: r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq
+White positions:
+1. e4 e5 2. Nf3 Nc6 [2 games] (08.07.2021)
+1. Nf3 Nc6 2. e4 e5 [1 game] (08.07.2021)
+Black positions:
+1. e4 e5 2. Nf3 Nc6 [1 game] (08.07.2021)
+`,
+	}, {
+		name: "FEN",
+		args: []string{"r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 2 3", "--color", "black"},
+		want: `Position: r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq
+Black positions:
+1. e4 e5 2. Nf3 Nc6 [1 game] (08.07.2021)
+`,
+	}, {
+		name: "NotFound",
+		args: []string{"d4"},
+		err:  ErrPositionNotFound,
+	}, {
+		name: "InvalidFEN",
+		args: []string{"8/8/8 w"},
+		err:  ErrInvalidFEN,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewSearchCmd()
+			buffer := new(bytes.Buffer)
+			cmd.SetOut(buffer)
+			cmd.SetErr(io.Discard)
+			cmd.SetArgs(append([]string{path}, tt.args...))
+			if err := cmd.Execute(); !errors.Is(err, tt.err) {
+				t.Fatalf("expected \"%v\" error, got \"%v\"", tt.err, err)
+			}
+			if got := buffer.String(); tt.err == nil && got != tt.want {
+				t.Errorf("expected:\n%v\ngot:\n%v", tt.want, got)
+			}
+		})
+	}
+
+	cmd := NewSearchCmd()
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+	cmd.SetArgs([]string{path, "e5"})
+	if err := cmd.Execute(); err == nil {
+		t.Error("expected an error for an illegal move")
+	}
+}
@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/Hofsiedge/ChessOpeningAnalyzer/internal/analysis"
+	"github.com/Hofsiedge/ChessOpeningAnalyzer/internal/positions"
+	"github.com/spf13/cobra"
+)
+
+var (
+	WeaknessesLimitFlag      int
+	WeaknessesThresholdsFlag analysis.Thresholds
+)
+
+func NewWeaknessesCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "weaknesses path [-n number_of_moves]",
+		Short: "list your weakest moves in an evaluated position graph",
+		Long: `list the moves you played that lose the most evaluation according to the engine (see eval).
+moves are classified as inaccuracies, mistakes and blunders by the evaluation loss in centipawns`,
+		Example: `$ openinganalyzer weaknesses openings.out -n 5 --blunder 200
+  Print out the 5 worst moves of the position graph stored in openings.out
+  counting moves that lose at least 2 pawns as blunders`,
+		ValidArgs: []string{"path"},
+		Args:      cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			graph, err := positions.LoadGraph(args[0])
+			if err != nil {
+				return err
+			}
+			weaknesses := analysis.FindWeaknesses(graph, WeaknessesThresholdsFlag)
+			if len(weaknesses) == 0 {
+				_, err = fmt.Fprintln(cmd.OutOrStdout(), "No weak moves found")
+				return err
+			}
+			if WeaknessesLimitFlag > 0 && len(weaknesses) > WeaknessesLimitFlag {
+				weaknesses = weaknesses[:WeaknessesLimitFlag]
+			}
+			for i, weakness := range weaknesses {
+				color := "black"
+				if weakness.White {
+					color = "white"
+				}
+				if _, err = fmt.Fprintf(cmd.OutOrStdout(), "%d. [%v] %v\n", i+1, color, weakness); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().IntVarP(&WeaknessesLimitFlag, "number", "n", 10, "number of moves to print, 0 to print all of them")
+	cmd.Flags().IntVar(&WeaknessesThresholdsFlag.Inaccuracy, "inaccuracy", analysis.DefaultThresholds.Inaccuracy,
+		"minimal evaluation loss (centipawns) of an inaccuracy")
+	cmd.Flags().IntVar(&WeaknessesThresholdsFlag.Mistake, "mistake", analysis.DefaultThresholds.Mistake,
+		"minimal evaluation loss (centipawns) of a mistake")
+	cmd.Flags().IntVar(&WeaknessesThresholdsFlag.Blunder, "blunder", analysis.DefaultThresholds.Blunder,
+		"minimal evaluation loss (centipawns) of a blunder")
+	return cmd
+}
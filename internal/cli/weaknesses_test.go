@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Hofsiedge/ChessOpeningAnalyzer/internal/fetching"
+	"github.com/Hofsiedge/ChessOpeningAnalyzer/internal/positions"
+)
+
+func TestWeaknesses(t *testing.T) {
+	graph, _ := positions.NewPositionGraph(3)
+	for _, game := range []fetching.UserGame{{
+		White:   true,
+		EndTime: time.Date(2021, 7, 8, 0, 0, 0, 0, time.UTC),
+		Moves:   []string{"e4", "e5", "Qh5"},
+	}, {
+		White:   false,
+		EndTime: time.Date(2021, 7, 8, 0, 0, 0, 0, time.UTC),
+		Moves:   []string{"d4", "f6"},
+	}} {
+		if err := graph.AddGame(game); err != nil {
+			t.Fatal(err)
+		}
+	}
+	scores := map[string]int{"e4": 30, "e4 e5": 30, "e4 e5 Qh5": -20, "d4": 30, "d4 f6": 150}
+	if err := graph.Walk(context.Background(), positions.Visitor{Pre: func(step positions.Step) error {
+		moves := make([]string, 0, step.Depth())
+		for _, move := range step.Path {
+			moves = append(moves, move.Move)
+		}
+		step.Move().To.Position.Evaluated = true
+		step.Move().To.Position.Evaluation = positions.Evaluation{Centipawns: scores[strings.Join(moves, " ")], BestMove: "Nf3"}
+		return nil
+	}}, positions.WalkOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "weaknesses_qux.bin")
+	if err := positions.DumpGraph(graph, path); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := NewWeaknessesCmd()
+	buffer := new(bytes.Buffer)
+	cmd.SetOut(buffer)
+	cmd.SetArgs([]string{path})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	expected := `1. [black] mistake (-1.20): 1. d4 f6 - played 1 times, engine prefers Nf3 (+0.30 -> +1.50)
+2. [white] inaccuracy (-0.50): 1. e4 e5 2. Qh5 - played 1 times, engine prefers Nf3 (+0.30 -> -0.20)
+`
+	if got := buffer.String(); got != expected {
+		t.Errorf("expected:\n%v\ngot:\n%v", expected, got)
+	}
+
+	buffer.Reset()
+	cmd.SetArgs([]string{path, "-n", "1", "--inaccuracy", "60", "--mistake", "200"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if got := buffer.String(); got != "1. [black] inaccuracy (-1.20): 1. d4 f6 - played 1 times, engine prefers Nf3 (+0.30 -> +1.50)\n" {
+		t.Errorf("unexpected output: %v", got)
+	}
+}
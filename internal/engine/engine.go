@@ -0,0 +1,421 @@
+package engine
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrEngineClosed     = errors.New("engine closed")
+	ErrUnexpectedOutput = errors.New("unexpected engine output")
+	ErrInvalidLimit     = errors.New("invalid search limit")
+	ErrUnknownOption    = errors.New("option not supported by the engine")
+)
+
+// stopTimeout is how long an engine is given to report its best move after a `stop` command
+const stopTimeout = time.Second
+
+// Limit restricts a single search. At least one of the fields has to be set
+type Limit struct {
+	Depth    int
+	Nodes    int
+	MoveTime time.Duration
+}
+
+func (l Limit) goCommand() (string, error) {
+	words := []string{"go"}
+	if l.Depth > 0 {
+		words = append(words, "depth", strconv.Itoa(l.Depth))
+	}
+	if l.Nodes > 0 {
+		words = append(words, "nodes", strconv.Itoa(l.Nodes))
+	}
+	if l.MoveTime > 0 {
+		words = append(words, "movetime", strconv.FormatInt(l.MoveTime.Milliseconds(), 10))
+	}
+	if len(words) == 1 {
+		return "", fmt.Errorf("%w: expected depth, nodes or movetime to be set", ErrInvalidLimit)
+	}
+	return strings.Join(words, " "), nil
+}
+
+// Score is a UCI score, always from the point of view of the side to move
+type Score struct {
+	Centipawns int
+	// Mate is true if the engine found a forced mate
+	Mate bool
+	// MateIn is the number of moves to mate, negative if the side to move is getting mated
+	MateIn int
+}
+
+// WDL is a win/draw/loss estimate in per mille from the point of view of the side to move
+type WDL struct {
+	Win  int
+	Draw int
+	Loss int
+}
+
+// Line is one of the principal variations reported by an engine in MultiPV mode
+type Line struct {
+	Depth int
+	Score Score
+	WDL   *WDL
+	// PV is in UCI notation
+	PV []string
+}
+
+// Result is the outcome of a single search
+type Result struct {
+	Depth int
+	Nodes int64
+	Score Score
+	// WDL is nil unless the engine reports it (see UCI_ShowWDL)
+	WDL *WDL
+	// PV is the principal variation in UCI notation
+	PV []string
+	// BestMove is in UCI notation. It is empty if there are no legal moves in the position
+	BestMove string
+	// Lines are the best lines ordered by the engine's preference, starting from the main line.
+	// There are as many lines as the MultiPV option allows
+	Lines []Line
+}
+
+// Engine is a client of a UCI chess engine
+type Engine struct {
+	Name   string
+	Author string
+	// Options are the names of the options the engine supports
+	Options map[string]bool
+
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	lines chan string
+	done  chan struct{}
+	// readErr is set by the reading goroutine before lines gets closed
+	readErr error
+}
+
+// Start launches a UCI engine located at path and performs the UCI handshake
+func Start(ctx context.Context, path string, args ...string) (*Engine, error) {
+	cmd := exec.Command(path, args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("engine.Start: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("engine.Start: %w", err)
+	}
+	if err = cmd.Start(); err != nil {
+		return nil, fmt.Errorf("engine.Start: %w", err)
+	}
+	e := newEngine(stdout, stdin)
+	e.cmd = cmd
+	if err = e.handshake(ctx); err != nil {
+		_ = e.Close()
+		return nil, fmt.Errorf("engine.Start: %w", err)
+	}
+	return e, nil
+}
+
+// newEngine wraps the engine's output and input streams without performing the handshake
+func newEngine(stdout io.Reader, stdin io.WriteCloser) *Engine {
+	e := &Engine{
+		Options: make(map[string]bool),
+		stdin:   stdin,
+		lines:   make(chan string, 64),
+		done:    make(chan struct{}),
+	}
+	go func() {
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			select {
+			case e.lines <- scanner.Text():
+			case <-e.done:
+			}
+		}
+		e.readErr = scanner.Err()
+		close(e.lines)
+	}()
+	return e
+}
+
+func (e *Engine) send(command string) error {
+	if _, err := io.WriteString(e.stdin, command+"\n"); err != nil {
+		return fmt.Errorf("%w: %v", ErrEngineClosed, err)
+	}
+	return nil
+}
+
+func (e *Engine) readLine(ctx context.Context) (string, error) {
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case line, ok := <-e.lines:
+		if !ok {
+			if e.readErr != nil {
+				return "", fmt.Errorf("%w: %v", ErrEngineClosed, e.readErr)
+			}
+			return "", ErrEngineClosed
+		}
+		return strings.TrimSpace(line), nil
+	}
+}
+
+// readUntil reads lines until one equals to token
+func (e *Engine) readUntil(ctx context.Context, token string) error {
+	for {
+		line, err := e.readLine(ctx)
+		if err != nil {
+			return err
+		}
+		if line == token {
+			return nil
+		}
+	}
+}
+
+func (e *Engine) handshake(ctx context.Context) error {
+	if err := e.send("uci"); err != nil {
+		return err
+	}
+	for {
+		line, err := e.readLine(ctx)
+		if err != nil {
+			return err
+		}
+		switch {
+		case line == "uciok":
+			return nil
+		case strings.HasPrefix(line, "id name "):
+			e.Name = strings.TrimPrefix(line, "id name ")
+		case strings.HasPrefix(line, "id author "):
+			e.Author = strings.TrimPrefix(line, "id author ")
+		case strings.HasPrefix(line, "option name "):
+			name := strings.TrimPrefix(line, "option name ")
+			if i := strings.Index(name, " type "); i != -1 {
+				name = name[:i]
+			}
+			e.Options[name] = true
+		}
+	}
+}
+
+// SetOption sets a UCI option. It should be followed by IsReady before the next search
+func (e *Engine) SetOption(name, value string) error {
+	return e.send(fmt.Sprintf("setoption name %s value %s", name, value))
+}
+
+// IsReady waits for the engine to process all the previous commands
+func (e *Engine) IsReady(ctx context.Context) error {
+	if err := e.send("isready"); err != nil {
+		return err
+	}
+	return e.readUntil(ctx, "readyok")
+}
+
+// NewGame tells the engine that the following searches are not related to the previous ones
+func (e *Engine) NewGame(ctx context.Context) error {
+	if err := e.send("ucinewgame"); err != nil {
+		return err
+	}
+	return e.IsReady(ctx)
+}
+
+// Evaluate searches the position given as a complete FEN string within the limit
+func (e *Engine) Evaluate(ctx context.Context, fen string, limit Limit) (*Result, error) {
+	goCommand, err := limit.goCommand()
+	if err != nil {
+		return nil, err
+	}
+	if err = e.send("position fen " + fen); err != nil {
+		return nil, err
+	}
+	if err = e.send(goCommand); err != nil {
+		return nil, err
+	}
+	result := new(Result)
+	hasScore := false
+	for {
+		line, err := e.readLine(ctx)
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			e.stop()
+			return nil, err
+		}
+		if err != nil {
+			return nil, err
+		}
+		words := strings.Fields(line)
+		if len(words) == 0 {
+			continue
+		}
+		switch words[0] {
+		case "info":
+			info, err := parseInfo(words[1:])
+			if err != nil {
+				e.stop()
+				return nil, fmt.Errorf("%w: %q: %v", ErrUnexpectedOutput, line, err)
+			}
+			if !info.hasScore {
+				continue
+			}
+			line := Line{Depth: info.depth, Score: info.score, WDL: info.wdl, PV: info.pv}
+			switch index := info.multiPV - 1; {
+			case index <= 0:
+				result.Depth = info.depth
+				result.Nodes = info.nodes
+				result.Score = info.score
+				result.WDL = info.wdl
+				result.PV = info.pv
+				hasScore = true
+				if len(result.Lines) == 0 {
+					result.Lines = append(result.Lines, line)
+				} else {
+					result.Lines[0] = line
+				}
+			case index < len(result.Lines):
+				result.Lines[index] = line
+			case index == len(result.Lines):
+				result.Lines = append(result.Lines, line)
+			}
+		case "bestmove":
+			if len(words) < 2 {
+				return nil, fmt.Errorf("%w: %q", ErrUnexpectedOutput, line)
+			}
+			if !hasScore {
+				return nil, fmt.Errorf("%w: no score reported before %q", ErrUnexpectedOutput, line)
+			}
+			if words[1] != "(none)" {
+				result.BestMove = words[1]
+			}
+			return result, nil
+		}
+	}
+}
+
+// stop interrupts the current search and discards its output
+func (e *Engine) stop() {
+	if err := e.send("stop"); err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), stopTimeout)
+	defer cancel()
+	for {
+		line, err := e.readLine(ctx)
+		if err != nil || strings.HasPrefix(line, "bestmove") {
+			return
+		}
+	}
+}
+
+// Close asks the engine to quit and waits for the process to exit
+func (e *Engine) Close() error {
+	_ = e.send("quit")
+	err := e.stdin.Close()
+	close(e.done)
+	if e.cmd == nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- e.cmd.Wait()
+	}()
+	select {
+	case err = <-done:
+	case <-time.After(stopTimeout):
+		_ = e.cmd.Process.Kill()
+		err = <-done
+	}
+	return err
+}
+
+type info struct {
+	depth    int
+	nodes    int64
+	multiPV  int
+	hasScore bool
+	score    Score
+	wdl      *WDL
+	pv       []string
+}
+
+// parseInfo parses the words of an `info` line following the `info` token
+func parseInfo(words []string) (info, error) {
+	var (
+		result info
+		err    error
+	)
+	for i := 0; i < len(words); i++ {
+		switch words[i] {
+		case "depth":
+			result.depth, err = intArgument(words, i)
+			i++
+		case "multipv":
+			result.multiPV, err = intArgument(words, i)
+			i++
+		case "nodes":
+			var nodes int
+			nodes, err = intArgument(words, i)
+			result.nodes = int64(nodes)
+			i++
+		case "score":
+			if i+2 >= len(words) {
+				return result, errors.New("incomplete score")
+			}
+			var value int
+			if value, err = strconv.Atoi(words[i+2]); err != nil {
+				return result, err
+			}
+			switch words[i+1] {
+			case "cp":
+				result.score = Score{Centipawns: value}
+			case "mate":
+				result.score = Score{Mate: true, MateIn: value}
+			default:
+				return result, fmt.Errorf("unknown score type %q", words[i+1])
+			}
+			result.hasScore = true
+			i += 2
+		case "lowerbound", "upperbound":
+			// a bound of a failed search window is not an evaluation of the position
+			result.hasScore = false
+		case "wdl":
+			if i+3 >= len(words) {
+				return result, errors.New("incomplete wdl")
+			}
+			values := make([]int, 3)
+			for j := range values {
+				if values[j], err = strconv.Atoi(words[i+1+j]); err != nil {
+					return result, err
+				}
+			}
+			result.wdl = &WDL{Win: values[0], Draw: values[1], Loss: values[2]}
+			i += 3
+		case "pv":
+			result.pv = append([]string(nil), words[i+1:]...)
+			return result, nil
+		case "string":
+			return result, nil
+		case "seldepth", "time", "nps", "hashfull", "tbhits", "cpuload", "currmovenumber", "currmove":
+			i++
+		}
+		if err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+func intArgument(words []string, i int) (int, error) {
+	if i+1 >= len(words) {
+		return 0, fmt.Errorf("missing value of %q", words[i])
+	}
+	return strconv.Atoi(words[i+1])
+}
@@ -0,0 +1,218 @@
+package engine
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeEngine answers UCI commands with canned search output
+type fakeEngine struct {
+	// searches maps a FEN to the lines printed in response to `go`
+	searches map[string][]string
+}
+
+func (f fakeEngine) serve(in io.Reader, out io.WriteCloser) {
+	defer out.Close()
+	scanner := bufio.NewScanner(in)
+	position := ""
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "uci":
+			fmt.Fprint(out, "id name Fake 1.0\nid author Tester\noption name Hash type spin default 16 min 1 max 1024\noption name UCI_ShowWDL type check default false\nuciok\n")
+		case line == "isready":
+			fmt.Fprintln(out, "readyok")
+		case strings.HasPrefix(line, "position fen "):
+			position = strings.TrimPrefix(line, "position fen ")
+		case strings.HasPrefix(line, "go"):
+			for _, response := range f.searches[position] {
+				fmt.Fprintln(out, response)
+			}
+		case line == "quit":
+			return
+		}
+	}
+}
+
+func startFake(t *testing.T, f fakeEngine) *Engine {
+	engineIn, fakeOut := io.Pipe()
+	fakeIn, engineOut := io.Pipe()
+	go f.serve(fakeIn, fakeOut)
+	e := newEngine(engineIn, engineOut)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := e.handshake(ctx); err != nil {
+		t.Fatalf("handshake failed: %v", err)
+	}
+	return e
+}
+
+func TestEngine_Evaluate(t *testing.T) {
+	const (
+		start = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+		mated = "rnb1kbnr/pppp1ppp/8/4p3/6Pq/5P2/PPPPP2P/RNBQKBNR w KQkq - 1 3"
+	)
+	e := startFake(t, fakeEngine{searches: map[string][]string{
+		start: {
+			"info string NNUE evaluation enabled",
+			"info depth 1 seldepth 1 multipv 1 score cp 18 nodes 20 nps 20000 time 1 pv e2e4",
+			"info depth 2 seldepth 3 multipv 1 score cp 35 wdl 60 900 40 nodes 120 nps 60000 time 2 pv e2e4 e7e5",
+			"info depth 3 seldepth 4 multipv 1 score cp 80 lowerbound nodes 300 nps 60000 time 5 pv d2d4",
+			"info depth 3 seldepth 4 multipv 1 score cp -20 upperbound nodes 400 nps 60000 time 6 pv e2e4",
+			"bestmove e2e4 ponder e7e5",
+		},
+		mated: {
+			"info depth 0 score mate 0",
+			"bestmove (none)",
+		},
+	}})
+	defer e.Close()
+	if e.Name != "Fake 1.0" || e.Author != "Tester" {
+		t.Errorf("unexpected engine id: %q by %q", e.Name, e.Author)
+	}
+	if !e.Options["Hash"] || !e.Options["UCI_ShowWDL"] || e.Options["Threads"] {
+		t.Errorf("unexpected engine options: %v", e.Options)
+	}
+	ctx := context.Background()
+	if err := e.NewGame(ctx); err != nil {
+		t.Fatal(err)
+	}
+	result, err := e.Evaluate(ctx, start, Limit{Depth: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &Result{
+		Depth:    2,
+		Nodes:    120,
+		Score:    Score{Centipawns: 35},
+		WDL:      &WDL{Win: 60, Draw: 900, Loss: 40},
+		PV:       []string{"e2e4", "e7e5"},
+		BestMove: "e2e4",
+		Lines: []Line{{
+			Depth: 2,
+			Score: Score{Centipawns: 35},
+			WDL:   &WDL{Win: 60, Draw: 900, Loss: 40},
+			PV:    []string{"e2e4", "e7e5"},
+		}},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %+v, got %+v", expected, result)
+	}
+
+	result, err = e.Evaluate(ctx, mated, Limit{MoveTime: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.BestMove != "" || result.Score != (Score{Mate: true}) {
+		t.Errorf("expected a mated position, got %+v", result)
+	}
+
+	if _, err = e.Evaluate(ctx, start, Limit{}); !errors.Is(err, ErrInvalidLimit) {
+		t.Errorf("expected %v, got %v", ErrInvalidLimit, err)
+	}
+}
+
+func TestEngine_EvaluateMultiPV(t *testing.T) {
+	const start = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+	e := startFake(t, fakeEngine{searches: map[string][]string{
+		start: {
+			"info depth 1 multipv 1 score cp 20 nodes 20 pv e2e4",
+			"info depth 1 multipv 2 score cp 10 nodes 40 pv d2d4",
+			"info depth 1 multipv 3 score cp 5 nodes 60 pv g1f3",
+			"info depth 2 multipv 1 score cp 30 nodes 100 pv d2d4 d7d5",
+			"info depth 2 multipv 2 score cp 25 nodes 140 pv e2e4 e7e5",
+			"bestmove d2d4 ponder d7d5",
+		},
+	}})
+	defer e.Close()
+	result, err := e.Evaluate(context.Background(), start, Limit{Depth: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Line{
+		{Depth: 2, Score: Score{Centipawns: 30}, PV: []string{"d2d4", "d7d5"}},
+		{Depth: 2, Score: Score{Centipawns: 25}, PV: []string{"e2e4", "e7e5"}},
+		{Depth: 1, Score: Score{Centipawns: 5}, PV: []string{"g1f3"}},
+	}
+	if !reflect.DeepEqual(result.Lines, expected) {
+		t.Errorf("expected %+v, got %+v", expected, result.Lines)
+	}
+	if result.BestMove != "d2d4" || result.Score.Centipawns != 30 || result.Nodes != 100 {
+		t.Errorf("expected the main line to be d4, got %+v", result)
+	}
+	if _, err = e.Evaluate(context.Background(), start, Limit{}); !errors.Is(err, ErrInvalidLimit) {
+		t.Errorf("expected %v, got %v", ErrInvalidLimit, err)
+	}
+}
+
+func TestEngine_EvaluateErrors(t *testing.T) {
+	const (
+		malformed = "8/8/8/8/8/8/8/K6k w - - 0 1"
+		silent    = "8/8/8/8/8/8/8/K5k1 w - - 0 1"
+	)
+	e := startFake(t, fakeEngine{searches: map[string][]string{
+		malformed: {"info depth two score cp 0", "bestmove a1a2"},
+		silent:    {"bestmove a1a2"},
+	}})
+	ctx := context.Background()
+	if _, err := e.Evaluate(ctx, malformed, Limit{Depth: 1}); !errors.Is(err, ErrUnexpectedOutput) {
+		t.Errorf("expected %v, got %v", ErrUnexpectedOutput, err)
+	}
+	if _, err := e.Evaluate(ctx, silent, Limit{Depth: 1}); !errors.Is(err, ErrUnexpectedOutput) {
+		t.Errorf("expected %v, got %v", ErrUnexpectedOutput, err)
+	}
+	if err := e.Close(); err != nil {
+		t.Errorf("unexpected error on close: %v", err)
+	}
+	if _, err := e.Evaluate(ctx, silent, Limit{Depth: 1}); !errors.Is(err, ErrEngineClosed) {
+		t.Errorf("expected %v, got %v", ErrEngineClosed, err)
+	}
+}
+
+func TestParseInfo(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    info
+		wantErr bool
+	}{{
+		line: "depth 24 seldepth 30 multipv 2 score mate -3 nodes 1000000 nps 1000 hashfull 10 tbhits 0 time 1000 pv e2e4 e7e5 g1f3",
+		want: info{
+			depth:    24,
+			nodes:    1000000,
+			multiPV:  2,
+			hasScore: true,
+			score:    Score{Mate: true, MateIn: -3},
+			pv:       []string{"e2e4", "e7e5", "g1f3"},
+		},
+	}, {
+		line: "depth 5 currmove e2e4 currmovenumber 1",
+		want: info{depth: 5},
+	}, {
+		line:    "depth 5 score cp",
+		wantErr: true,
+	}, {
+		line:    "depth 5 score wdl 1",
+		wantErr: true,
+	}, {
+		line:    "depth 5 score cp 1 wdl 1 2",
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			got, err := parseInfo(strings.Fields(tt.line))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+// Command fakeuci is a scripted UCI engine for tests. It answers searches with lines read from a JSON config
+// given by the -config flag or the FAKEUCI_CONFIG environment variable:
+//
+//	{
+//	  "name": "FakeUCI 1.0",
+//	  "options": ["Hash", "Threads", "MultiPV", "UCI_ShowWDL"],
+//	  "positions": {
+//	    "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq": {
+//	      "delay": "50ms",
+//	      "lines": ["info depth {depth} score cp -30 nodes 1000 pv c7c5"],
+//	      "bestmove": "c7c5"
+//	    }
+//	  },
+//	  "default": {"lines": ["info depth {depth} score cp 0 nodes 1"]}
+//	}
+//
+// Positions are matched by the first three fields of a FEN, the en passant square is ignored.
+// `{depth}` is replaced by the depth of the `go` command. A search waits for `delay` unless it is stopped,
+// then prints `lines` and `bestmove` (or `bestmove (none)`). A script with `"crash": true` makes the engine exit
+// with a non-zero code right after printing its lines, so that malformed output, slow responses and crashes
+// can all be scripted
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+type script struct {
+	Delay    string   `json:"delay"`
+	Lines    []string `json:"lines"`
+	BestMove string   `json:"bestmove"`
+	Crash    bool     `json:"crash"`
+}
+
+type config struct {
+	Name      string            `json:"name"`
+	Options   []string          `json:"options"`
+	Positions map[string]script `json:"positions"`
+	Default   *script           `json:"default"`
+}
+
+func loadConfig(path string) (*config, error) {
+	cfg := &config{Name: "FakeUCI"}
+	if path == "" {
+		return cfg, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("invalid config %v: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// positionKey drops the en passant square and move counters of a FEN
+func positionKey(fen string) string {
+	words := strings.Fields(fen)
+	if len(words) > 3 {
+		words = words[:3]
+	}
+	return strings.Join(words, " ")
+}
+
+// goDepth returns the depth argument of a `go` command or 1
+func goDepth(command string) string {
+	words := strings.Fields(command)
+	for i := 0; i+1 < len(words); i++ {
+		if words[i] == "depth" {
+			return words[i+1]
+		}
+	}
+	return "1"
+}
+
+type engine struct {
+	cfg      *config
+	out      *bufio.Writer
+	commands <-chan string
+	position string
+}
+
+func (e *engine) println(line string) {
+	_, _ = e.out.WriteString(line + "\n")
+	_ = e.out.Flush()
+}
+
+// search plays the script of the current position. It returns false if the engine has to quit
+func (e *engine) search(command string) bool {
+	s, found := e.cfg.Positions[positionKey(e.position)]
+	if !found {
+		if e.cfg.Default == nil {
+			s = script{Lines: []string{"info depth {depth} score cp 0 nodes 1"}}
+		} else {
+			s = *e.cfg.Default
+		}
+	}
+	if s.Delay != "" {
+		delay, err := time.ParseDuration(s.Delay)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "fakeuci: invalid delay %q: %v\n", s.Delay, err)
+			os.Exit(2)
+		}
+		select {
+		case <-time.After(delay):
+		case command, ok := <-e.commands:
+			if !ok || command == "quit" {
+				return false
+			}
+			// any other command (normally `stop`) interrupts the search
+		}
+	}
+	depth := goDepth(command)
+	for _, line := range s.Lines {
+		e.println(strings.ReplaceAll(line, "{depth}", depth))
+	}
+	if s.Crash {
+		os.Exit(1)
+	}
+	bestMove := s.BestMove
+	if bestMove == "" {
+		bestMove = "(none)"
+	}
+	e.println("bestmove " + bestMove)
+	return true
+}
+
+func (e *engine) run() {
+	for command := range e.commands {
+		switch {
+		case command == "uci":
+			e.println("id name " + e.cfg.Name)
+			e.println("id author ChessOpeningAnalyzer")
+			for _, option := range e.cfg.Options {
+				e.println("option name " + option + " type string default <empty>")
+			}
+			e.println("uciok")
+		case command == "isready":
+			e.println("readyok")
+		case strings.HasPrefix(command, "position fen "):
+			e.position = strings.TrimPrefix(command, "position fen ")
+		case strings.HasPrefix(command, "go"):
+			if !e.search(command) {
+				return
+			}
+		case command == "quit":
+			return
+		}
+	}
+}
+
+func main() {
+	configPath := flag.String("config", os.Getenv("FAKEUCI_CONFIG"), "path to a JSON config")
+	flag.Parse()
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fakeuci: %v\n", err)
+		os.Exit(2)
+	}
+	commands := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			commands <- strings.TrimSpace(scanner.Text())
+		}
+		close(commands)
+	}()
+	e := &engine{
+		cfg:      cfg,
+		out:      bufio.NewWriter(os.Stdout),
+		commands: commands,
+	}
+	e.run()
+}
@@ -0,0 +1,134 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeUCIPath is the scripted engine built from ./fakeuci by TestMain
+var fakeUCIPath string
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "fakeuci")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fakeUCIPath = filepath.Join(dir, "fakeuci")
+	if output, err := exec.Command("go", "build", "-o", fakeUCIPath, "./fakeuci").CombinedOutput(); err != nil {
+		fmt.Fprintf(os.Stderr, "could not build the fake engine: %v\n%s", err, output)
+		os.Exit(1)
+	}
+	if err = os.Setenv("FAKEUCI_CONFIG", "../../testdata/engine/fakeuci.json"); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+const (
+	fakeStart     = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+	fakeSlow      = "rnbqkbnr/pppp1ppp/8/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R b KQkq - 1 2"
+	fakeMalformed = "rnbqkbnr/pppppppp/8/8/3P4/8/PPP1PPPP/RNBQKBNR b KQkq - 0 1"
+	fakeCrash     = "rnbqkbnr/pppppppp/8/8/2P5/8/PP1PPPPP/RNBQKBNR b KQkq - 0 1"
+)
+
+func TestStart_FakeUCI(t *testing.T) {
+	ctx := context.Background()
+	e, err := Start(ctx, fakeUCIPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+	if e.Name != "FakeUCI 1.0" || !e.Options["MultiPV"] {
+		t.Errorf("unexpected engine: %q with options %v", e.Name, e.Options)
+	}
+	if err = e.configure(ctx, PoolConfig{Threads: 1, Hash: 16, MultiPV: 2}); err != nil {
+		t.Fatal(err)
+	}
+	result, err := e.Evaluate(ctx, fakeStart, Limit{Depth: 12})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Depth != 12 || result.BestMove != "e2e4" || len(result.Lines) != 2 || result.WDL == nil {
+		t.Errorf("unexpected result: %+v", result)
+	}
+
+	// a slow search is stopped when the context expires and the engine stays usable
+	timeout, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err = e.Evaluate(timeout, fakeSlow, Limit{Depth: 12}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+	if result, err = e.Evaluate(ctx, fakeStart, Limit{Depth: 10}); err != nil || result.Depth != 10 {
+		t.Errorf("expected the engine to recover after stop, got %+v (%v)", result, err)
+	}
+
+	if _, err = e.Evaluate(ctx, fakeMalformed, Limit{Depth: 12}); !errors.Is(err, ErrUnexpectedOutput) {
+		t.Errorf("expected %v, got %v", ErrUnexpectedOutput, err)
+	}
+	if _, err = e.Evaluate(ctx, fakeCrash, Limit{Depth: 12}); !errors.Is(err, ErrEngineClosed) {
+		t.Errorf("expected %v, got %v", ErrEngineClosed, err)
+	}
+}
+
+func TestStartPool_FakeUCI(t *testing.T) {
+	ctx := context.Background()
+	pool, err := StartPool(ctx, PoolConfig{Path: fakeUCIPath, Size: 2, MultiPV: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pool.Size() != 2 || pool.Name() != "FakeUCI 1.0" {
+		t.Errorf("unexpected pool: %v engines named %q", pool.Size(), pool.Name())
+	}
+	if result, err := pool.Evaluate(ctx, fakeStart, Limit{Depth: 5}); err != nil || result.Score.Centipawns != 30 {
+		t.Errorf("unexpected result: %+v (%v)", result, err)
+	}
+	if err = pool.Close(); err != nil {
+		t.Error(err)
+	}
+
+	_, err = StartPool(ctx, PoolConfig{Path: fakeUCIPath, Size: 1, Options: map[string]string{"Hash": "64", "Contempt": "10"}})
+	if !errors.Is(err, ErrUnknownOption) {
+		t.Errorf("expected %v, got %v", ErrUnknownOption, err)
+	}
+	if _, err = Start(ctx, "../../testdata/engine/fakeuci.json"); err == nil {
+		t.Error("expected an error starting a non-executable file")
+	}
+}
+
+func TestPool_Evaluate_Crash(t *testing.T) {
+	ctx := context.Background()
+	pool, err := StartPool(ctx, PoolConfig{Path: fakeUCIPath, Size: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	if _, err = pool.Evaluate(ctx, fakeCrash, Limit{Depth: 5}); !errors.Is(err, ErrEngineClosed) {
+		t.Errorf("expected %v, got %v", ErrEngineClosed, err)
+	}
+	// the crashed engine is replaced by a new process
+	if result, err := pool.Evaluate(ctx, fakeStart, Limit{Depth: 5}); err != nil || result.Score.Centipawns != 30 {
+		t.Errorf("unexpected result after a crash: %+v (%v)", result, err)
+	}
+
+	// an engine that cannot be restarted is dropped
+	pool.cfg.Path = "non-existent-engine"
+	if _, err = pool.Evaluate(ctx, fakeCrash, Limit{Depth: 5}); !errors.Is(err, ErrEngineClosed) {
+		t.Errorf("expected %v, got %v", ErrEngineClosed, err)
+	}
+	if pool.Size() != 0 {
+		t.Errorf("expected the crashed engine to be dropped, got %v engines", pool.Size())
+	}
+	if _, err = pool.Evaluate(ctx, fakeStart, Limit{Depth: 5}); !errors.Is(err, ErrEngineClosed) {
+		t.Errorf("expected %v from an empty pool, got %v", ErrEngineClosed, err)
+	}
+}
@@ -0,0 +1,195 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// PoolConfig describes a pool of identical engine processes
+type PoolConfig struct {
+	Path string
+	// Size is the number of engine processes
+	Size int
+	// Threads and Hash (in MB) are set for every engine of the pool. Zero values keep engine defaults
+	Threads int
+	Hash    int
+	// MultiPV is the number of best lines every search reports
+	MultiPV int
+	// Options are set before Threads and Hash, so the latter take precedence if they are set in both
+	Options map[string]string
+}
+
+// Pool is a set of engine processes. It is safe for concurrent use:
+// every Evaluate call takes an idle engine and returns it once the search is over.
+// An engine that fails a search is replaced by a new process, or dropped if it cannot be restarted
+type Pool struct {
+	cfg  PoolConfig
+	idle chan *Engine
+
+	mu      sync.Mutex
+	engines []*Engine
+}
+
+// StartPool launches cfg.Size engines and applies the options to each of them
+func StartPool(ctx context.Context, cfg PoolConfig) (*Pool, error) {
+	if cfg.Size < 1 {
+		return nil, fmt.Errorf("engine.StartPool: expected size >= 1, got: %v", cfg.Size)
+	}
+	pool := &Pool{
+		cfg:     cfg,
+		engines: make([]*Engine, 0, cfg.Size),
+		idle:    make(chan *Engine, cfg.Size),
+	}
+	for i := 0; i < cfg.Size; i++ {
+		e, err := Start(ctx, cfg.Path)
+		if err != nil {
+			_ = pool.Close()
+			return nil, err
+		}
+		pool.engines = append(pool.engines, e)
+		if err = e.configure(ctx, cfg); err != nil {
+			_ = pool.Close()
+			return nil, fmt.Errorf("engine.StartPool: %w", err)
+		}
+		pool.idle <- e
+	}
+	return pool, nil
+}
+
+// restart starts and configures a new engine in place of e
+func (p *Pool) restart(ctx context.Context, e *Engine) (*Engine, error) {
+	_ = e.Close()
+	restarted, err := Start(ctx, p.cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+	if err = restarted.configure(ctx, p.cfg); err != nil {
+		_ = restarted.Close()
+		return nil, err
+	}
+	return restarted, nil
+}
+
+// replace restarts a failed engine and makes the new one idle. An engine that cannot be restarted is dropped
+func (p *Pool) replace(ctx context.Context, e *Engine) {
+	restarted, err := p.restart(ctx, e)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, engine := range p.engines {
+		if engine != e {
+			continue
+		}
+		if err != nil {
+			p.engines = append(p.engines[:i], p.engines[i+1:]...)
+		} else {
+			p.engines[i] = restarted
+		}
+		break
+	}
+	switch {
+	case err == nil:
+		p.idle <- restarted
+	case len(p.engines) == 0:
+		// wakes up the callers waiting for an idle engine
+		close(p.idle)
+	}
+}
+
+func (e *Engine) configure(ctx context.Context, cfg PoolConfig) error {
+	names := make([]string, 0, len(cfg.Options))
+	for name := range cfg.Options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !e.Options[name] {
+			return fmt.Errorf("%w: %v", ErrUnknownOption, name)
+		}
+		if err := e.SetOption(name, cfg.Options[name]); err != nil {
+			return err
+		}
+	}
+	if cfg.Threads > 0 {
+		if err := e.SetOption("Threads", strconv.Itoa(cfg.Threads)); err != nil {
+			return err
+		}
+	}
+	if cfg.Hash > 0 {
+		if err := e.SetOption("Hash", strconv.Itoa(cfg.Hash)); err != nil {
+			return err
+		}
+	}
+	if cfg.MultiPV > 1 {
+		if err := e.SetOption("MultiPV", strconv.Itoa(cfg.MultiPV)); err != nil {
+			return err
+		}
+	}
+	if e.Options["UCI_ShowWDL"] {
+		if err := e.SetOption("UCI_ShowWDL", "true"); err != nil {
+			return err
+		}
+	}
+	return e.NewGame(ctx)
+}
+
+// Size returns the number of engines in the pool
+func (p *Pool) Size() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.engines)
+}
+
+// Name returns the name the engines reported during the UCI handshake
+func (p *Pool) Name() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.engines) == 0 {
+		return ""
+	}
+	return p.engines[0].Name
+}
+
+// Evaluate waits for an idle engine and evaluates the position with it.
+// It returns ErrEngineClosed if every engine of the pool has failed and could not be restarted
+func (p *Pool) Evaluate(ctx context.Context, fen string, limit Limit) (*Result, error) {
+	var e *Engine
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case idle, ok := <-p.idle:
+		if !ok {
+			return nil, ErrEngineClosed
+		}
+		e = idle
+	}
+	result, err := e.Evaluate(ctx, fen, limit)
+	if err != nil && !errors.Is(err, ErrInvalidLimit) &&
+		!errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+		// the engine has crashed or is out of sync with its output
+		p.replace(ctx, e)
+		return nil, err
+	}
+	p.idle <- e
+	return result, err
+}
+
+// Close closes every engine of the pool
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	errs := make([]string, 0)
+	for _, e := range p.engines {
+		if err := e.Close(); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("engine.Pool.Close: %v", strings.Join(errs, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestPool_Evaluate(t *testing.T) {
+	searches := make(map[string][]string)
+	fens := make([]string, 8)
+	for i := range fens {
+		// move counters make FENs distinct for the fake engine
+		fens[i] = fmt.Sprintf("8/8/8/8/8/8/8/K6k w - - 0 %d", i+1)
+		searches[fens[i]] = []string{
+			fmt.Sprintf("info depth 1 score cp %d nodes 1 pv a1a2", i+1),
+			"bestmove a1a2",
+		}
+	}
+	ctx := context.Background()
+	pool := &Pool{idle: make(chan *Engine, 3)}
+	for i := 0; i < 3; i++ {
+		e := startFake(t, fakeEngine{searches: searches})
+		if err := e.configure(ctx, PoolConfig{Threads: 2, Hash: 32}); err != nil {
+			t.Fatal(err)
+		}
+		pool.engines = append(pool.engines, e)
+		pool.idle <- e
+	}
+	if pool.Size() != 3 || pool.Name() != "Fake 1.0" {
+		t.Errorf("unexpected pool: %v engines named %q", pool.Size(), pool.Name())
+	}
+
+	wg := sync.WaitGroup{}
+	results := make([]*Result, len(fens))
+	errs := make([]error, len(fens))
+	for i, fen := range fens {
+		wg.Add(1)
+		go func(i int, fen string) {
+			defer wg.Done()
+			results[i], errs[i] = pool.Evaluate(ctx, fen, Limit{Depth: 1})
+		}(i, fen)
+	}
+	wg.Wait()
+	for i := range fens {
+		if errs[i] != nil {
+			t.Errorf("%v: unexpected error: %v", fens[i], errs[i])
+			continue
+		}
+		if results[i].Score.Centipawns != i+1 {
+			t.Errorf("%v: expected %v cp, got %v", fens[i], i+1, results[i].Score.Centipawns)
+		}
+	}
+	if err := pool.Close(); err != nil {
+		t.Error(err)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	empty := &Pool{idle: make(chan *Engine)}
+	if _, err := empty.Evaluate(cancelled, fens[0], Limit{Depth: 1}); err != context.Canceled {
+		t.Errorf("expected %v, got %v", context.Canceled, err)
+	}
+}
+
+func TestStartPool(t *testing.T) {
+	if _, err := StartPool(context.Background(), PoolConfig{Path: "non-existent-engine", Size: 0}); err == nil {
+		t.Error("expected an error for an empty pool")
+	}
+	if _, err := StartPool(context.Background(), PoolConfig{Path: "non-existent-engine", Size: 2}); err == nil {
+		t.Error("expected an error for a non-existent engine")
+	}
+}
@@ -0,0 +1,95 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+var ErrUnknownProfile = errors.New("unknown engine profile")
+
+// Profile is a named engine setup stored in a profiles file:
+//
+//	{
+//	  "quick": {"engine": "/usr/bin/stockfish", "options": {"Hash": "256"}, "depth": 12},
+//	  "deep": {
+//	    "engine": "/usr/bin/stockfish",
+//	    "options": {"Hash": "4096", "Threads": "8", "SyzygyPath": "/opt/syzygy"},
+//	    "depth": 30,
+//	    "budget": "2h"
+//	  }
+//	}
+//
+// Durations (movetime and budget) are given in time.ParseDuration format
+type Profile struct {
+	// Engine is a path to a UCI engine executable
+	Engine string
+	// Options are sent to every engine process with `setoption` as they are
+	Options map[string]string
+	Limit   Limit
+	// Budget is the total wall-clock time of an evaluation, zero means no budget
+	Budget time.Duration
+}
+
+// UnmarshalJSON implements json.Unmarshaler interface
+func (p *Profile) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Engine   string            `json:"engine"`
+		Options  map[string]string `json:"options"`
+		Depth    int               `json:"depth"`
+		Nodes    int               `json:"nodes"`
+		MoveTime string            `json:"movetime"`
+		Budget   string            `json:"budget"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*p = Profile{
+		Engine:  raw.Engine,
+		Options: raw.Options,
+		Limit:   Limit{Depth: raw.Depth, Nodes: raw.Nodes},
+	}
+	for _, duration := range []struct {
+		name  string
+		value string
+		dest  *time.Duration
+	}{{"movetime", raw.MoveTime, &p.Limit.MoveTime}, {"budget", raw.Budget, &p.Budget}} {
+		if duration.value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(duration.value)
+		if err != nil {
+			return fmt.Errorf("invalid %v: %w", duration.name, err)
+		}
+		*duration.dest = parsed
+	}
+	return nil
+}
+
+// LoadProfiles reads a profiles file mapping profile names to profiles
+func LoadProfiles(path string) (map[string]Profile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	profiles := make(map[string]Profile)
+	if err = json.Unmarshal(data, &profiles); err != nil {
+		return nil, fmt.Errorf("engine.LoadProfiles: %v: %w", path, err)
+	}
+	return profiles, nil
+}
+
+// LoadProfile reads a single profile from a profiles file
+func LoadProfile(path, name string) (Profile, error) {
+	profiles, err := LoadProfiles(path)
+	if err != nil {
+		return Profile{}, err
+	}
+	profile, found := profiles[name]
+	if !found {
+		return Profile{}, fmt.Errorf("%w: %q is not defined in %v", ErrUnknownProfile, name, path)
+	}
+	return profile, nil
+}
@@ -0,0 +1,34 @@
+package engine
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLoadProfile(t *testing.T) {
+	path := "../../testdata/engine/profiles.json"
+	profile, err := LoadProfile(path, "deep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Profile{
+		Engine:  "/usr/bin/stockfish",
+		Options: map[string]string{"Hash": "4096", "Threads": "8", "SyzygyPath": "/opt/syzygy", "Contempt": "0"},
+		Limit:   Limit{Depth: 30, MoveTime: 30 * time.Second},
+		Budget:  2 * time.Hour,
+	}
+	if !reflect.DeepEqual(profile, expected) {
+		t.Errorf("expected %+v, got %+v", expected, profile)
+	}
+	if _, err = LoadProfile(path, "unknown"); !errors.Is(err, ErrUnknownProfile) {
+		t.Errorf("expected %v, got %v", ErrUnknownProfile, err)
+	}
+	if _, err = LoadProfile("../../testdata/engine/profiles_invalid.json", "broken"); err == nil {
+		t.Error("expected an error for a profile with an invalid duration")
+	}
+	if _, err = LoadProfile("../../testdata/engine/non-existent.json", "deep"); err == nil {
+		t.Error("expected an error for a missing profiles file")
+	}
+}
@@ -1,10 +1,13 @@
 package chesscom
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"path"
 	"strings"
 	"sync"
 	"time"
@@ -29,9 +32,14 @@ type monthYearPair struct {
 	Month int
 }
 
-func (f *Fetcher) workerPool(workers int, jobs <-chan monthYearPair, username string, filter fetching.FilterOptions) (<-chan []*fetching.UserGame, <-chan error) {
-	results := make(chan []*fetching.UserGame, workers)
-	errors := make(chan error, workers)
+// Stream implements fetching.GameStreamer interface. Months are fetched by the workers concurrently,
+// so the games of different months are mixed unless there is a single worker
+func (f *Fetcher) Stream(ctx context.Context, username string, filter fetching.FilterOptions, workers int) <-chan fetching.StreamedGame {
+	if workers < 1 {
+		workers = 1
+	}
+	stream := make(chan fetching.StreamedGame, workers)
+	jobs := make(chan monthYearPair)
 
 	wg := sync.WaitGroup{}
 	wg.Add(workers)
@@ -40,76 +48,57 @@ func (f *Fetcher) workerPool(workers int, jobs <-chan monthYearPair, username st
 		go func() {
 			defer wg.Done()
 			for p := range jobs {
-				games, err := f.fetchMonthGames(fetchParams{
+				err := f.streamMonthGames(ctx, fetchParams{
 					userName: username,
 					year:     p.Year,
 					month:    p.Month,
 					until:    filter.NumberOfMovesCap,
+				}, func(game *fetching.UserGame) error {
+					return fetching.Send(ctx, stream, fetching.StreamedGame{Game: game})
 				})
-				if err != nil {
-					errors <- fmt.Errorf("error parsing %d.%02d games of %v: %v", p.Year, p.Month, username, err)
-					continue
+				if err != nil && ctx.Err() == nil {
+					err = fmt.Errorf("error parsing %d.%02d games of %v: %v", p.Year, p.Month, username, err)
+					_ = fetching.Send(ctx, stream, fetching.StreamedGame{Err: err})
 				}
-				results <- games
 			}
 		}()
 	}
+	// sending jobs
 	go func() {
-		wg.Wait()
-		close(results)
-		close(errors)
-	}()
-	return results, errors
-}
-
-func (f *Fetcher) aggregate(results <-chan []*fetching.UserGame, errs <-chan error) (chan []*fetching.UserGame, chan error) {
-	wg := sync.WaitGroup{}
-	wg.Add(2)
-
-	// game aggregator
-	games := make([]*fetching.UserGame, 0)
-	go func() {
-		for result := range results {
-			games = append(games, result...)
-		}
-		wg.Done()
-	}()
-	// error aggregator
-	aggregatedErrors := make([]string, 0)
-	go func() {
-		for err := range errs {
-			aggregatedErrors = append(aggregatedErrors, err.Error())
+		defer close(jobs)
+		currentDate := time.Date(filter.TimePeriodStart.Year(), filter.TimePeriodStart.Month(), 1, 0, 0, 0, 0, time.UTC)
+		for currentDate.Before(filter.TimePeriodEnd) {
+			year, month, _ := currentDate.Date()
+			select {
+			case jobs <- monthYearPair{year, int(month)}:
+			case <-ctx.Done():
+				return
+			}
+			currentDate = currentDate.AddDate(0, 1, 0)
 		}
-		wg.Done()
 	}()
-
-	gamesCh, errCh := make(chan []*fetching.UserGame, 1), make(chan error, 1)
 	go func() {
 		wg.Wait()
-		gamesCh <- games
-		if len(aggregatedErrors) != 0 {
-			errCh <- fmt.Errorf(strings.Join(aggregatedErrors, "\n"))
-		} else {
-			errCh <- nil
-		}
+		close(stream)
 	}()
-	return gamesCh, errCh
+	return stream
 }
 
+// Fetch implements fetching.GameFetcher interface. The errors of all the months are joined
 func (f *Fetcher) Fetch(username string, filter fetching.FilterOptions, workers int) ([]*fetching.UserGame, error) {
-	jobs := make(chan monthYearPair, workers)
-	results, errs := f.workerPool(workers, jobs, username, filter)
-	games, err := f.aggregate(results, errs)
-
-	// sending jobs
-	currentDate := time.Date(filter.TimePeriodStart.Year(), filter.TimePeriodStart.Month(), 1, 0, 0, 0, 0, time.UTC)
-	for currentDate.Before(filter.TimePeriodEnd) {
-		year, month, _ := currentDate.Date()
-		jobs <- monthYearPair{year, int(month)}
-		currentDate = currentDate.AddDate(0, 1, 0)
-	}
-	close(jobs)
-	return <-games, <-err
+	games := make([]*fetching.UserGame, 0)
+	aggregatedErrors := make([]string, 0)
+	for item := range f.Stream(context.Background(), username, filter, workers) {
+		if item.Err != nil {
+			aggregatedErrors = append(aggregatedErrors, item.Err.Error())
+			continue
+		}
+		games = append(games, item.Game)
+	}
+	if len(aggregatedErrors) != 0 {
+		return games, errors.New(strings.Join(aggregatedErrors, "\n"))
+	}
+	return games, nil
 }
 
 type User struct {
@@ -124,7 +113,7 @@ type Game struct {
 	Pgn         string `json:"pgn"`
 	TimeControl string `json:"time_control"`
 	EndTime     int64  `json:"end_time"`
-	// Rated       bool         `json:"rated"`
+	Rated       bool   `json:"rated"`
 	// Fen         string       `json:"-"`
 	TimeClass string `json:"time_class"`
 	Rules     string `json:"rules"`
@@ -143,14 +132,54 @@ func (g Game) UserGame(username string, until int) (*fetching.UserGame, error) {
 	if err != nil {
 		return nil, fmt.Errorf("chesscom.UserGame: %w", err)
 	}
+	// chess.com usernames are case-insensitive, the API returns them as registered
+	white := strings.EqualFold(g.White.Username, username)
+	user, opponent := g.Black, g.White
+	if white {
+		user, opponent = g.White, g.Black
+	}
 	userGame := &fetching.UserGame{
-		White:   g.White.Username == username,
-		EndTime: time.Unix(g.EndTime, 0),
-		Moves:   moves,
+		White:          white,
+		EndTime:        time.Unix(g.EndTime, 0),
+		Moves:          moves,
+		Result:         user.result(),
+		Platform:       "chesscom",
+		ID:             path.Base(g.Url),
+		URL:            g.Url,
+		Opponent:       opponent.Username,
+		UserRating:     user.Rating,
+		OpponentRating: opponent.Rating,
+		TimeControl:    g.TimeControl,
+		TimeClass:      g.TimeClass,
+		Rated:          g.Rated,
+		Termination:    tagValue(game, "Termination"),
+		ECO:            tagValue(game, "ECO"),
 	}
 	return userGame, nil
 }
 
+// tagValue returns the value of a PGN tag pair of the game or an empty string if there is no such tag
+func tagValue(game *chess.Game, key string) string {
+	if tag := game.GetTagPair(key); tag != nil {
+		return tag.Value
+	}
+	return ""
+}
+
+// result converts a chess.com result code of the player into a game result
+func (u User) result() fetching.Result {
+	switch u.Result {
+	case "win":
+		return fetching.Win
+	case "agreed", "repetition", "stalemate", "insufficient", "50move", "timevsinsufficient":
+		return fetching.Draw
+	case "":
+		return fetching.UnknownResult
+	}
+	// checkmated, resigned, timeout, abandoned, etc.
+	return fetching.Loss
+}
+
 type filterPredicate func(game *Game) bool
 
 type fetchParams struct {
@@ -161,15 +190,20 @@ type fetchParams struct {
 	until    int
 }
 
-func (f *Fetcher) fetchMonthGames(p fetchParams) ([]*fetching.UserGame, error) {
+// streamMonthGames passes the games of a month to send one by one as they are decoded. It stops at the first error
+func (f *Fetcher) streamMonthGames(ctx context.Context, p fetchParams, send func(*fetching.UserGame) error) error {
 	var (
 		err      error
+		request  *http.Request
 		response *http.Response
 	)
-	if response, err = http.Get(
-		fmt.Sprintf("%v/player/%v/games/%d/%02d", f.URL, strings.ToLower(p.userName), p.year, p.month),
+	if request, err = http.NewRequestWithContext(ctx, http.MethodGet,
+		fmt.Sprintf("%v/player/%v/games/%d/%02d", f.URL, strings.ToLower(p.userName), p.year, p.month), nil,
 	); err != nil {
-		return nil, err
+		return err
+	}
+	if response, err = http.DefaultClient.Do(request); err != nil {
+		return err
 	}
 	defer response.Body.Close()
 
@@ -177,12 +211,12 @@ func (f *Fetcher) fetchMonthGames(p fetchParams) ([]*fetching.UserGame, error) {
 		apiError := new(ErrorResponse)
 		rawData, err := io.ReadAll(response.Body)
 		if err != nil {
-			return nil, fmt.Errorf("network error: %v", err)
+			return fmt.Errorf("network error: %v", err)
 		}
 		if err = json.Unmarshal(rawData, apiError); err != nil {
-			return nil, fmt.Errorf("could not unmarshal an error response")
+			return fmt.Errorf("could not unmarshal an error response")
 		}
-		return nil, fmt.Errorf("non-OK StatusCode: %v; error: %v", response.StatusCode, *apiError)
+		return fmt.Errorf("non-OK StatusCode: %v; error: %v", response.StatusCode, *apiError)
 	}
 
 	// response.Body should be treated as a stream due to potentially big number of games
@@ -193,30 +227,24 @@ func (f *Fetcher) fetchMonthGames(p fetchParams) ([]*fetching.UserGame, error) {
 			return game.Rules != "chess"
 		}
 	}
-	var chessComGames []*Game
-	chessComGames, err = parseChessComGames(decoder, p.filter)
-	if err != nil {
-		return nil, err
-	}
-	genericGames := make([]*fetching.UserGame, 0, len(chessComGames))
-	for _, game := range chessComGames {
+	return parseChessComGames(decoder, p.filter, func(game *Game) error {
 		userGame, err := game.UserGame(p.userName, p.until)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		genericGames = append(genericGames, userGame)
-	}
-	return genericGames, nil
+		return send(userGame)
+	})
 }
 
-func parseChessComGames(decoder *json.Decoder, filter filterPredicate) ([]*Game, error) {
+// parseChessComGames decodes the games of a game archive one by one and passes them to handle.
+// It stops at the first error
+func parseChessComGames(decoder *json.Decoder, filter filterPredicate, handle func(*Game) error) error {
 	// read `{"games":`
 	for i := 0; i < 3; i++ {
 		if t, err := decoder.Token(); err != nil {
-			return nil, fmt.Errorf("error reading JSON token (%v) from the start of a game archive: %v", t, err)
+			return fmt.Errorf("error reading JSON token (%v) from the start of a game archive: %v", t, err)
 		}
 	}
-	games := make([]*Game, 0)
 	for decoder.More() {
 		game := new(Game)
 		if err := decoder.Decode(game); err != nil {
@@ -224,17 +252,18 @@ func parseChessComGames(decoder *json.Decoder, filter filterPredicate) ([]*Game,
 			if err.Error() == "not at beginning of value" {
 				break
 			}
-			return nil, fmt.Errorf("error decoding a chess game: %v", err)
+			return fmt.Errorf("error decoding a chess game: %v", err)
 		}
 		// filtering out chess variants other than standard
 		if filter(game) {
 			continue
 		}
-		games = append(games, game)
+		if err := handle(game); err != nil {
+			return err
+		}
 	}
 	if _, err := decoder.Token(); err != nil {
-		return nil, fmt.Errorf("error decoding the end of chess games JSON: %v", err)
+		return fmt.Errorf("error decoding the end of chess games JSON: %v", err)
 	}
-
-	return games, nil
+	return nil
 }
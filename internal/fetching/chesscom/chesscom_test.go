@@ -1,6 +1,7 @@
 package chesscom
 
 import (
+	"context"
 	"encoding/json"
 	"github.com/Hofsiedge/ChessOpeningAnalyzer/internal/fetching"
 	"github.com/notnil/chess"
@@ -29,15 +30,32 @@ type testCase struct {
 	ExpectedError    string
 }
 
+// fetchMonth streams the games of the month of p with a single worker
+func fetchMonth(f *Fetcher, p fetchParams) ([]*fetching.UserGame, error) {
+	start := time.Date(p.year, time.Month(p.month), 1, 0, 0, 0, 0, time.UTC)
+	games := make([]*fetching.UserGame, 0)
+	for item := range f.Stream(context.Background(), p.userName, fetching.FilterOptions{
+		TimePeriodStart:  start,
+		TimePeriodEnd:    start.AddDate(0, 1, 0),
+		NumberOfMovesCap: p.until,
+	}, 1) {
+		if item.Err != nil {
+			return nil, item.Err
+		}
+		games = append(games, item.Game)
+	}
+	return games, nil
+}
+
 func evaluateTestCases(testCases []testCase, t *testing.T) {
 	for i, testCase := range testCases {
 		ts := httptest.NewServer(http.HandlerFunc(testCase.Server.mockChessCom))
 		fetcher := Fetcher{URL: ts.URL}
-		resp, err := fetcher.fetchMonthGames(testCase.Params)
+		resp, err := fetchMonth(&fetcher, testCase.Params)
 		if testCase.IsErr {
 			if err == nil {
 				t.Errorf("case %v. Expected error but got nil", i)
-			} else if !strings.HasPrefix(err.Error(), testCase.ExpectedError) {
+			} else if !strings.HasSuffix(err.Error(), testCase.ExpectedError) {
 				t.Errorf("case %v. Expected \"%v\" but got \"%v\"", i, testCase.ExpectedError, err)
 			}
 			continue
@@ -116,9 +134,19 @@ func TestChessComUnmarshalling(t *testing.T) {
 	}, {
 		fixture: "../../../testdata/fetching/trivial.json",
 		want: []*fetching.UserGame{{
-			White:   true,
-			EndTime: time.Unix(1622664410, 0),
-			Moves:   []string{"e4", "e5"},
+			White:          true,
+			EndTime:        time.Unix(1622664410, 0),
+			Moves:          []string{"e4", "e5"},
+			Result:         fetching.Loss,
+			Platform:       "chesscom",
+			ID:             "game_url",
+			URL:            "game_url",
+			Opponent:       "buzz",
+			UserRating:     525,
+			OpponentRating: 595,
+			TimeControl:    "60",
+			TimeClass:      "bullet",
+			Rated:          true,
 		}},
 		name: "UnmarshalTrivial",
 	}}
@@ -137,7 +165,7 @@ func TestChessComUnmarshalling(t *testing.T) {
 				_ = r.Body.Close()
 			}))
 			f := Fetcher{URL: ts.URL}
-			games, err := f.fetchMonthGames(fetchParams{userName: "qux"})
+			games, err := fetchMonth(&f, fetchParams{userName: "qux", year: 2021, month: 6})
 			if err == nil && testCase.isError {
 				t.Errorf("Expected error, got nil")
 			}
@@ -181,4 +209,111 @@ func TestChessComRealData(t *testing.T) {
 	if len(games) != 28 {
 		t.Errorf("expected 28 games, got %v", len(games))
 	}
+	found := false
+	for _, game := range games {
+		if game.ID != "18972424955" {
+			continue
+		}
+		found = true
+		if game.White || game.Opponent != "udizzz" || game.UserRating != 614 || game.ECO != "A53" ||
+			game.Termination != "udizzz won on time" || game.Result != fetching.Loss {
+			t.Errorf("unexpected game metadata: %+v", game)
+		}
+	}
+	if !found {
+		t.Error("expected game 18972424955 to be fetched")
+	}
+}
+
+func TestChessComStream(t *testing.T) {
+	responseData, err := os.ReadFile("../../../testdata/fetching/sample_response.json")
+	if err != nil {
+		t.Fatal("could not open a sample file")
+	}
+	srv := server{
+		Response:   responseData,
+		StatusCode: 200,
+		HasBody:    true,
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/2021/03") {
+			server{Response: ErrorResponse{Code: 0, Message: "not found"}, StatusCode: 404, HasBody: true}.mockChessCom(w, r)
+			return
+		}
+		srv.mockChessCom(w, r)
+	}))
+	defer ts.Close()
+	fetcher := Fetcher{URL: ts.URL}
+	filter := fetching.FilterOptions{
+		TimePeriodStart:  time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+		TimePeriodEnd:    time.Date(2021, 6, 15, 0, 0, 0, 0, time.UTC),
+		NumberOfMovesCap: 5,
+	}
+
+	// the errors of a month are delivered along with the games of the other months
+	games, errs := 0, 0
+	for item := range fetcher.Stream(context.Background(), "Hofsiedge", filter, 3) {
+		if item.Err != nil {
+			errs++
+			if !strings.HasPrefix(item.Err.Error(), "error parsing 2021.03 games of Hofsiedge") {
+				t.Errorf("unexpected error: %v", item.Err)
+			}
+			continue
+		}
+		games++
+	}
+	if games != 5*28 || errs != 1 {
+		t.Errorf("expected %v games and 1 error, got %v games and %v errors", 5*28, games, errs)
+	}
+
+	// the stream is closed soon after the context is cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := fetcher.Stream(ctx, "Hofsiedge", filter, 3)
+	<-stream
+	cancel()
+	timeout := time.After(5 * time.Second)
+	for closed := false; !closed; {
+		select {
+		case _, ok := <-stream:
+			closed = !ok
+		case <-timeout:
+			t.Fatal("expected the stream to be closed after the cancellation")
+		}
+	}
+}
+
+func TestUser_result(t *testing.T) {
+	tests := map[string]fetching.Result{
+		"win":        fetching.Win,
+		"checkmated": fetching.Loss,
+		"timeout":    fetching.Loss,
+		"resigned":   fetching.Loss,
+		"agreed":     fetching.Draw,
+		"repetition": fetching.Draw,
+		"stalemate":  fetching.Draw,
+		"50move":     fetching.Draw,
+		"":           fetching.UnknownResult,
+	}
+	for code, want := range tests {
+		if got := (User{Result: code}).result(); got != want {
+			t.Errorf("%q: expected %v, got %v", code, want, got)
+		}
+	}
+}
+
+func TestGame_UserGame_Username(t *testing.T) {
+	game := Game{
+		Pgn:   "[Event \"Live Chess\"]\n1. e4 e5 1-0\n",
+		White: User{Username: "Qux", Result: "win"},
+		Black: User{Username: "buzz", Result: "resigned"},
+	}
+	for _, username := range []string{"qux", "QUX", "Qux"} {
+		userGame, err := game.UserGame(username, 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !userGame.White || userGame.Result != fetching.Win || userGame.Opponent != "buzz" {
+			t.Errorf("%v: expected a white win against buzz, got %+v", username, userGame)
+		}
+	}
 }
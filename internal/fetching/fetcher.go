@@ -1,6 +1,7 @@
 package fetching
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"time"
@@ -13,10 +14,68 @@ var (
 	ArgumentError     = errors.New("invalid argument")
 )
 
+// Result is the outcome of a game from the user's point of view
+type Result uint8
+
+const (
+	UnknownResult Result = iota
+	Win
+	Draw
+	Loss
+)
+
+// String implements fmt.Stringer interface
+func (r Result) String() string {
+	switch r {
+	case Win:
+		return "win"
+	case Draw:
+		return "draw"
+	case Loss:
+		return "loss"
+	}
+	return "unknown"
+}
+
 type UserGame struct {
 	White   bool
 	EndTime time.Time
 	Moves   []string
+	Result  Result
+	// Platform is the platform the game was played on: "chesscom" or "lichess"
+	Platform string
+	// ID identifies the game on its platform, URL links to it
+	ID  string
+	URL string
+	// Opponent is the opponent's username
+	Opponent       string
+	UserRating     int
+	OpponentRating int
+	// TimeControl is given in the PGN format: `180+2` is 3 minutes with a 2 seconds increment,
+	// daily games are like `1/259200`, correspondence lichess games are `-`
+	TimeControl string
+	// TimeClass is "ultraBullet", "bullet", "blitz", "rapid", "classical", "daily" or "correspondence"
+	TimeClass string
+	Rated     bool
+	// Termination tells how the game ended as the platform describes it, e.g. "Time forfeit", "Hofsiedge won by resignation"
+	Termination string
+	// ECO is the code of the opening in the Encyclopaedia of Chess Openings, e.g. "C20"
+	ECO string
+	// Evaluations are the platform's computer analysis of the positions after each of Moves.
+	// The slice is either empty or as long as Moves, with nil entries for positions that were not analysed
+	Evaluations []*Evaluation
+}
+
+// Evaluation is a platform's assessment of a position, always from white's point of view
+type Evaluation struct {
+	// Source is the platform that evaluated the position, e.g. "lichess"
+	Source     string
+	Centipawns int
+	Mate       bool
+	// MateIn is the number of moves to mate, negative if white is getting mated
+	MateIn int
+	// Depth is zero if the platform did not report it
+	Depth int
 }
 
 type ConvertibleToUserGame interface {
@@ -35,6 +94,30 @@ type GameFetcher interface {
 	Fetch(username string, filter FilterOptions, workers int) ([]*UserGame, error)
 }
 
+// StreamedGame is an item of a game stream: a game or an error, e.g. of a game that could not be read
+type StreamedGame struct {
+	Game *UserGame
+	Err  error
+}
+
+// GameStreamer delivers the games as they are downloaded instead of collecting them
+type GameStreamer interface {
+	// Stream returns a channel of the games and the errors met on the way. Errors do not stop the stream,
+	// unless nothing can be fetched after them. The channel is closed when the games are over or ctx is done,
+	// cancelling ctx is the way to abandon the stream
+	Stream(ctx context.Context, username string, filter FilterOptions, workers int) <-chan StreamedGame
+}
+
+// Send delivers an item of a stream unless ctx is done first, in which case it returns the error of ctx
+func Send(ctx context.Context, stream chan<- StreamedGame, item StreamedGame) error {
+	select {
+	case stream <- item:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // ParseMoves parses first `until` moves from `game`
 // If until == 0 all the moves are parsed
 func ParseMoves(game *chess.Game, until int) ([]string, error) {
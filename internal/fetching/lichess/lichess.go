@@ -1,12 +1,17 @@
 package lichess
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"net/http"
 	"net/url"
+	"path"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -30,60 +35,76 @@ type Fetcher struct {
 	URL url.URL
 }
 
-func (f *Fetcher) Fetch(username string, filter fetching.FilterOptions, _ int) ([]*fetching.UserGame, error) {
-	var (
-		err      error
-		response *http.Response
-	)
+// Stream implements fetching.GameStreamer interface. Games with invalid PGN tags are reported as ErrInvalidPGNTags
+// errors, the stream ends after the other ones
+func (f *Fetcher) Stream(ctx context.Context, username string, filter fetching.FilterOptions, _ int) <-chan fetching.StreamedGame {
+	stream := make(chan fetching.StreamedGame)
+	go func() {
+		defer close(stream)
+		body, err := f.request(ctx, username, filter)
+		if err != nil {
+			_ = fetching.Send(ctx, stream, fetching.StreamedGame{Err: err})
+			return
+		}
+		defer body.Close()
+		err = parseLichessPGN(body, username, filter, func(item fetching.StreamedGame) error {
+			return fetching.Send(ctx, stream, item)
+		})
+		if err != nil && ctx.Err() == nil {
+			_ = fetching.Send(ctx, stream, fetching.StreamedGame{Err: err})
+		}
+	}()
+	return stream
+}
+
+// Fetch implements fetching.GameFetcher interface. Games with invalid PGN tags are skipped
+func (f *Fetcher) Fetch(username string, filter fetching.FilterOptions, workers int) ([]*fetching.UserGame, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	games := make([]*fetching.UserGame, 0)
+	invalidGames := 0
+	for item := range f.Stream(ctx, username, filter, workers) {
+		switch {
+		case item.Err == nil:
+			games = append(games, item.Game)
+		case errors.Is(item.Err, ErrInvalidPGNTags):
+			invalidGames++
+		default:
+			return nil, item.Err
+		}
+	}
+	if invalidGames != 0 {
+		log.Printf("got %d invalid games", invalidGames)
+	}
+	return games, nil
+}
+
+// request performs the export request and returns the body of the response with the games in PGN
+func (f *Fetcher) request(ctx context.Context, username string, filter fetching.FilterOptions) (io.ReadCloser, error) {
 	requestURL, err := f.makeLichessURL(username, filter)
 	if err != nil {
 		return nil, fmt.Errorf("lichess.Fetch: %w", err)
 	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("lichess.Fetch: %w", err)
+	}
 	log.Printf("performing GET request to %s", requestURL.String())
-	if response, err = http.Get(requestURL.String()); err != nil {
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
 		log.Printf("attempted to perform a GET request to %s", &requestURL)
 		return nil, fmt.Errorf("lichess.Fetch: http.Get error: %w", err)
 	}
-	defer response.Body.Close()
 	switch response.StatusCode {
 	case http.StatusOK:
-		break
+		return response.Body, nil
 	case http.StatusNotFound:
-		return nil, fmt.Errorf("%w: %w", ErrRequestError, fetching.UserNotFoundError)
+		err = fmt.Errorf("%w: %w", ErrRequestError, fetching.UserNotFoundError)
 	default:
-		return nil, fmt.Errorf("%w: status code %d", ErrRequestError, response.StatusCode)
+		err = fmt.Errorf("%w: status code %d", ErrRequestError, response.StatusCode)
 	}
-
-	gamesCh, errsCh := parseLichessPGN(response.Body, username, filter)
-	games := make([]*fetching.UserGame, 0)
-	invalidGames := 0
-	for {
-		select {
-		case game, ok := <-gamesCh:
-			if !ok {
-				gamesCh = nil
-			} else {
-				games = append(games, game)
-			}
-		case err, ok := <-errsCh:
-			if !ok {
-				errsCh = nil
-			} else {
-				if errors.Is(err, ErrInvalidPGNTags) {
-					invalidGames++
-				} else if err != nil {
-					return nil, err
-				}
-			}
-		}
-		if (gamesCh == nil) && (errsCh == nil) {
-			break
-		}
-	}
-	if invalidGames != 0 {
-		log.Printf("got %d invalid games", invalidGames)
-	}
-	return games, nil
+	response.Body.Close()
+	return nil, err
 }
 
 func (f *Fetcher) makeLichessURL(username string, filter fetching.FilterOptions) (url.URL, error) {
@@ -97,6 +118,8 @@ func (f *Fetcher) makeLichessURL(username string, filter fetching.FilterOptions)
 	queryParams := url.Values{
 		"since": timeConverter(filter.TimePeriodStart),
 		"until": timeConverter(filter.TimePeriodEnd),
+		// server-side analysis is exported as [%eval ...] comments
+		"evals": []string{"true"},
 	}
 	if filter.Color != chess.NoColor {
 		if filter.Color == chess.White {
@@ -116,56 +139,121 @@ func (f *Fetcher) makeLichessURL(username string, filter fetching.FilterOptions)
 	return requestURL, nil
 }
 
-func parseLichessPGN(reader io.Reader, username string, filter fetching.FilterOptions) (<-chan *fetching.UserGame, <-chan error) {
+// parseLichessPGN reads the games of the user from a PGN export and passes them to send one by one,
+// along with the errors of the games that could not be read. It stops when send returns an error
+func parseLichessPGN(reader io.Reader, username string, filter fetching.FilterOptions, send func(fetching.StreamedGame) error) error {
 	decoder := chess.NewScanner(reader)
-	games := make(chan *fetching.UserGame)
-	errs := make(chan error)
+	for decoder.Scan() {
+		game := decoder.Next()
+		userGame, err := readGame(game, username, filter)
+		if userGame == nil && err == nil {
+			continue
+		}
+		if err = send(fetching.StreamedGame{Game: userGame, Err: err}); err != nil {
+			return err
+		}
+	}
+	if decoder.Err() != nil && !errors.Is(decoder.Err(), io.EOF) {
+		return fmt.Errorf("lichess.parseLichessPGN: %w", decoder.Err())
+	}
+	return nil
+}
 
-	go func() {
-		for decoder.Scan() {
-			game := decoder.Next()
+// readGame converts a game of the export. It returns nil without an error if the game does not match the filter
+func readGame(game *chess.Game, username string, filter fetching.FilterOptions) (*fetching.UserGame, error) {
+	// reading color
+	userPlaysWhite, err := userIsWhite(game, username)
+	if err != nil {
+		return nil, err
+	}
+	if (filter.Color == chess.White) != userPlaysWhite {
+		return nil, nil
+	}
 
-			// reading color
-			userPlaysWhite, err := userIsWhite(game, username)
-			if err != nil {
-				errs <- err
-				continue
-			}
-			if (filter.Color == chess.White) != userPlaysWhite {
-				continue
-			}
+	// reading date and time
+	timestamp, err := getTimeFromGame(game)
+	if err != nil {
+		return nil, err
+	}
+	if !(timestamp.After(filter.TimePeriodStart) && timestamp.Before(filter.TimePeriodEnd)) {
+		return nil, nil
+	}
 
-			// reading date and time
-			timestamp, err := getTimeFromGame(game)
-			if err != nil {
-				errs <- err
-				continue
-			}
-			if !(timestamp.After(filter.TimePeriodStart) && timestamp.Before(filter.TimePeriodEnd)) {
+	moves, err := fetching.ParseMoves(game, filter.NumberOfMovesCap)
+	if err != nil {
+		return nil, fmt.Errorf("lichess.parseLichessPGN: could not read moves: %w", err)
+	}
+
+	evaluations, err := parseEvaluations(game, len(moves))
+	if err != nil {
+		return nil, fmt.Errorf("lichess.parseLichessPGN: could not read evaluations: %w", err)
+	}
+
+	userGame := fetching.UserGame{
+		White:       userPlaysWhite,
+		EndTime:     timestamp,
+		Moves:       moves,
+		Result:      gameResult(game, userPlaysWhite),
+		Evaluations: evaluations,
+	}
+	readMetadata(game, &userGame)
+	return &userGame, nil
+}
+
+// evalComment matches the evaluation of a move comment: `[%eval 0.17]`, `[%eval #-3]` or `[%eval 0.17,23]`
+var evalComment = regexp.MustCompile(`\[%eval\s+(#?-?[0-9.]+)(?:,(\d+))?\]`)
+
+// parseEvaluations reads the evaluations of the positions after the first `until` moves of the game.
+// It returns nil if none of them was analysed
+func parseEvaluations(game *chess.Game, until int) ([]*fetching.Evaluation, error) {
+	comments := game.Comments()
+	if len(comments) > until {
+		comments = comments[:until]
+	}
+	var evaluations []*fetching.Evaluation
+	for i, moveComments := range comments {
+		for _, comment := range moveComments {
+			match := evalComment.FindStringSubmatch(comment)
+			if match == nil {
 				continue
 			}
-
-			moves, err := fetching.ParseMoves(game, filter.NumberOfMovesCap)
+			evaluation, err := parseEvaluation(match[1], match[2])
 			if err != nil {
-				errs <- fmt.Errorf("lichess.parseLichessPGN: could not read moves: %w", err)
-				continue
+				return nil, err
 			}
-
-			userGame := fetching.UserGame{
-				White:   userPlaysWhite,
-				EndTime: timestamp,
-				Moves:   moves,
+			if evaluations == nil {
+				evaluations = make([]*fetching.Evaluation, until)
 			}
-			games <- &userGame
-		}
-		if decoder.Err() != nil && !errors.Is(decoder.Err(), io.EOF) {
-			errs <- fmt.Errorf("lichess.parseLichessPGN: %w", decoder.Err())
+			evaluations[i] = evaluation
+			break
 		}
-		close(games)
-		close(errs)
-	}()
+	}
+	return evaluations, nil
+}
 
-	return games, errs
+// parseEvaluation converts a score of an [%eval] comment (in pawns or `#N` for mates) and an optional depth
+func parseEvaluation(score, depth string) (*fetching.Evaluation, error) {
+	evaluation := &fetching.Evaluation{Source: "lichess"}
+	if depth != "" {
+		var err error
+		if evaluation.Depth, err = strconv.Atoi(depth); err != nil {
+			return nil, err
+		}
+	}
+	if strings.HasPrefix(score, "#") {
+		mateIn, err := strconv.Atoi(score[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid mate score %q: %w", score, err)
+		}
+		evaluation.Mate, evaluation.MateIn = true, mateIn
+		return evaluation, nil
+	}
+	pawns, err := strconv.ParseFloat(score, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid score %q: %w", score, err)
+	}
+	evaluation.Centipawns = int(math.Round(pawns * 100))
+	return evaluation, nil
 }
 
 func userIsWhite(game *chess.Game, username string) (bool, error) {
@@ -190,6 +278,86 @@ func userIsWhite(game *chess.Game, username string) (bool, error) {
 	return userPlaysWhite, nil
 }
 
+// readMetadata fills the platform-specific fields of the game from its PGN tags
+func readMetadata(game *chess.Game, userGame *fetching.UserGame) {
+	userColor, opponentColor := "Black", "White"
+	if userGame.White {
+		userColor, opponentColor = "White", "Black"
+	}
+	userGame.Platform = "lichess"
+	userGame.URL = tagValue(game, "Site")
+	userGame.ID = path.Base(userGame.URL)
+	userGame.Opponent = tagValue(game, opponentColor)
+	// unrated players have `?` ratings
+	userGame.UserRating, _ = strconv.Atoi(tagValue(game, userColor+"Elo"))
+	userGame.OpponentRating, _ = strconv.Atoi(tagValue(game, opponentColor+"Elo"))
+	userGame.TimeControl = tagValue(game, "TimeControl")
+	userGame.TimeClass = timeClass(userGame.TimeControl)
+	// e.g. `Rated Blitz game`, `Casual Rapid game`
+	userGame.Rated = strings.HasPrefix(tagValue(game, "Event"), "Rated")
+	userGame.Termination = tagValue(game, "Termination")
+	userGame.ECO = tagValue(game, "ECO")
+}
+
+// timeClass classifies a time control the way lichess does: by the estimated game duration,
+// which is the initial time plus 40 increments
+func timeClass(timeControl string) string {
+	parts := strings.Split(timeControl, "+")
+	if len(parts) != 2 {
+		return "correspondence"
+	}
+	initial, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return ""
+	}
+	increment, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return ""
+	}
+	switch duration := initial + 40*increment; {
+	case duration < 30:
+		return "ultraBullet"
+	case duration < 180:
+		return "bullet"
+	case duration < 480:
+		return "blitz"
+	case duration < 1500:
+		return "rapid"
+	}
+	return "classical"
+}
+
+// tagValue returns the value of a PGN tag pair of the game or an empty string if there is no such tag
+func tagValue(game *chess.Game, key string) string {
+	if tag := game.GetTagPair(key); tag != nil {
+		return tag.Value
+	}
+	return ""
+}
+
+// gameResult reads the Result tag of the game from the user's point of view
+func gameResult(game *chess.Game, userPlaysWhite bool) fetching.Result {
+	tag := game.GetTagPair("Result")
+	if tag == nil {
+		return fetching.UnknownResult
+	}
+	switch tag.Value {
+	case "1/2-1/2":
+		return fetching.Draw
+	case "1-0":
+		if userPlaysWhite {
+			return fetching.Win
+		}
+		return fetching.Loss
+	case "0-1":
+		if userPlaysWhite {
+			return fetching.Loss
+		}
+		return fetching.Win
+	}
+	return fetching.UnknownResult
+}
+
 func getTimeFromGame(game *chess.Game) (time.Time, error) {
 	dateTag := game.GetTagPair("UTCDate")
 	timeTag := game.GetTagPair("UTCTime")
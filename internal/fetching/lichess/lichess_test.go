@@ -122,8 +122,19 @@ func TestLichessRequest(t *testing.T) {
 			Response:   readFixture(testDataPath + "single_game.pgn"),
 		},
 		ExpectedResponse: []*fetching.UserGame{{
-			White:   true,
-			EndTime: time.Date(2023, 6, 1, 1, 2, 3, 0, time.UTC),
+			White:          true,
+			EndTime:        time.Date(2023, 6, 1, 1, 2, 3, 0, time.UTC),
+			Result:         fetching.Loss,
+			Platform:       "lichess",
+			ID:             "link_to_game",
+			URL:            "https://lichess.org/link_to_game",
+			Opponent:       "Player2",
+			UserRating:     1500,
+			OpponentRating: 1500,
+			TimeControl:    "600+0",
+			TimeClass:      "rapid",
+			Termination:    "Normal",
+			ECO:            "C47",
 			Moves: []string{
 				"e4", "e5", "Nf3", "Nf6", "Nc3", "Nc6", "d4", "d6", "d5",
 				"Ne7", "Bb5+", "Bd7", "Bxd7+", "Qxd7", "Be3", "Qg4",
@@ -143,6 +154,63 @@ func TestLichessRequest(t *testing.T) {
 				NumberOfMovesCap: 0,
 			},
 		},
+	}, {
+		Name: "Game with server-side analysis",
+		Server: server{
+			StatusCode: 200,
+			HasBody:    true,
+			Response:   readFixture(testDataPath + "analysed_game.pgn"),
+		},
+		ExpectedResponse: []*fetching.UserGame{{
+			White:          false,
+			EndTime:        time.Date(2023, 6, 2, 10, 20, 30, 0, time.UTC),
+			Moves:          []string{"e4", "e5", "Qh5", "Nc6", "Bc4", "Nf6"},
+			Result:         fetching.Loss,
+			Platform:       "lichess",
+			ID:             "link_to_game",
+			URL:            "https://lichess.org/link_to_game",
+			Opponent:       "Player2",
+			UserRating:     1480,
+			OpponentRating: 1520,
+			TimeControl:    "180+0",
+			TimeClass:      "blitz",
+			Rated:          true,
+			Termination:    "Normal",
+			ECO:            "C20",
+			Evaluations: []*fetching.Evaluation{
+				{Source: "lichess", Centipawns: 36},
+				{Source: "lichess", Centipawns: 24, Depth: 22},
+				{Source: "lichess", Centipawns: 0},
+				{Source: "lichess", Centipawns: 4},
+				{Source: "lichess", Centipawns: -18},
+				{Source: "lichess", Mate: true, MateIn: 1},
+			},
+		}},
+		Args: FetchArgs{
+			Username: "Player1",
+			Filter: fetching.FilterOptions{
+				TimePeriodStart:  time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+				TimePeriodEnd:    time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+				Color:            chess.Black,
+				NumberOfMovesCap: 6,
+			},
+		},
 	}}
 	evaluateTestCases(testCases, t)
 }
+
+func TestTimeClass(t *testing.T) {
+	tests := map[string]string{
+		"15+0":   "ultraBullet",
+		"60+1":   "bullet",
+		"180+2":  "blitz",
+		"600+5":  "rapid",
+		"1800+0": "classical",
+		"-":      "correspondence",
+	}
+	for timeControl, want := range tests {
+		if got := timeClass(timeControl); got != want {
+			t.Errorf("%v: expected %v, got %v", timeControl, want, got)
+		}
+	}
+}
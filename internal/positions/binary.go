@@ -1,28 +1,243 @@
 package positions
 
 import (
+	"bytes"
 	"encoding/gob"
+	"fmt"
 	"os"
+	"path/filepath"
+
+	"github.com/notnil/chess"
 )
 
-// DumpGraph encodes the graph into a binary file at the provided path
+// DumpGraph encodes the graph into a binary file at the provided path, see CompactGraph.
+// The file is replaced atomically, so an interrupted dump never corrupts a previous one.
+// A replaced file keeps its permissions, a new one is created with 0644
 func DumpGraph(graph *PositionGraph, path string) error {
-	file, err := os.Create(path)
+	data, err := graph.Compact().MarshalBinary()
+	if err != nil {
+		return err
+	}
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
 	if err != nil {
 		return err
 	}
-	encoder := gob.NewEncoder(file)
-	return encoder.Encode(graph)
+	defer os.Remove(file.Name())
+	// CreateTemp makes the file readable by the owner only
+	if err = file.Chmod(mode); err != nil {
+		file.Close()
+		return err
+	}
+	if _, err = file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
 }
 
-// LoadGraph decodes the graph from a file generated with DumpGraph
+// LoadGraph decodes the graph from a file generated with DumpGraph.
+// Graphs before version 4 were gob encoded, they are decoded and migrated:
+//   - version 0 graphs had a single index shared by both trees, so separate indexes are built for each colour.
+//     Lines that had been mixed in a shared node stay in both repertoires, fetch the games again to separate them
+//   - version 0 graphs did not count the games, so every move is counted as played once
+//   - LastPlayed of version 0 and 1 graphs is the date of the first game, so it becomes FirstPlayed as well
+//     and the monthly history is left empty
+//   - graphs before version 3 were indexed by FEN, so the keys are computed from the FENs. The FENs have no
+//     en passant squares, so the keys of such positions lack the en passant file until they are re-keyed below
+//
+// The starting positions of graphs before version 5 were marked as evaluated with a placeholder 0.00,
+// the placeholder is dropped so that eval evaluates them. The moves of graphs before version 6 have no monthly history,
+// see Move.PlayedBetween. Position.FEN of graphs before version 7 had no en passant squares, they are restored
+// by replaying the moves, the positions with an en passant capture are re-keyed and their evaluations are dropped
 func LoadGraph(path string) (*PositionGraph, error) {
-	file, err := os.Open(path)
+	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
-	decoder := gob.NewDecoder(file)
+	if bytes.HasPrefix(data, []byte(compactMagic)) {
+		compact := new(CompactGraph)
+		if err = compact.UnmarshalBinary(data); err != nil {
+			return nil, err
+		}
+		graph := compact.Graph()
+		if compact.Version < 5 {
+			graph.dropRootPlaceholders()
+		}
+		if compact.Version < 7 {
+			if err = graph.restoreEnPassant(); err != nil {
+				return graph, err
+			}
+		}
+		graph.Version = GraphVersion
+		return graph, nil
+	}
+	decoder := gob.NewDecoder(bytes.NewReader(data))
 	graph := new(PositionGraph)
-	err = decoder.Decode(graph)
-	return graph, err
+	if err = decoder.Decode(graph); err != nil {
+		return graph, err
+	}
+	if graph.Version < 3 {
+		if err = graph.computeKeys(); err != nil {
+			return graph, err
+		}
+	}
+	graph.relink()
+	if graph.Version < 1 {
+		for _, node := range append(graph.Nodes(), graph.WhitePositions, graph.BlackPositions) {
+			for _, move := range node.Moves {
+				if move.Played == 0 {
+					move.Played = 1
+				}
+			}
+		}
+	}
+	if graph.Version < 2 {
+		for _, node := range append(graph.Nodes(), graph.WhitePositions, graph.BlackPositions) {
+			node.FirstPlayed = node.LastPlayed
+		}
+	}
+	graph.dropRootPlaceholders()
+	if err = graph.restoreEnPassant(); err != nil {
+		return graph, err
+	}
+	graph.Version = GraphVersion
+	return graph, nil
+}
+
+// dropRootPlaceholders marks the starting positions as not evaluated unless they were evaluated by an engine
+func (g *PositionGraph) dropRootPlaceholders() {
+	for _, root := range []*PositionNode{g.WhitePositions, g.BlackPositions} {
+		if e := root.Position.Evaluation; root.Position.Evaluated && e.Depth == 0 && e.Source == "" && e.BestMove == "" {
+			root.Position.Evaluated = false
+			root.Position.Evaluation = Evaluation{}
+		}
+	}
+}
+
+// restoreEnPassant adds the en passant squares that Position.FEN lacked before version 7, see TruncateFEN.
+// The engine evaluated such positions without the en passant capture, so their evaluations are dropped.
+// The keys of graphs migrated from version 2 lacked the en passant files as well, so such positions are re-keyed
+func (g *PositionGraph) restoreEnPassant() error {
+	visited := make(map[*PositionNode]bool)
+	var (
+		positionIndex map[Key]*PositionNode
+		replay        func(node *PositionNode, board *chess.Position) error
+	)
+	replay = func(node *PositionNode, board *chess.Position) error {
+		for _, move := range node.Moves {
+			if visited[move.To] {
+				continue
+			}
+			visited[move.To] = true
+			next, err := playMove(board, move.Move)
+			if err != nil {
+				return fmt.Errorf("%w: illegal move %v: %w", ErrCorruptGraph, move.Move, err)
+			}
+			if fen := positionFEN(next); fen != move.To.Position.FEN {
+				move.To.Position.FEN = fen
+				move.To.Position.Evaluated = false
+				move.To.Position.Evaluation = Evaluation{}
+				move.To.Alternatives = nil
+				if key := NewKey(next); key != move.To.Position.Key {
+					if positionIndex[move.To.Position.Key] == move.To {
+						delete(positionIndex, move.To.Position.Key)
+					}
+					move.To.Position.Key = key
+					positionIndex[key] = move.To
+				}
+			}
+			if err = replay(move.To, next); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, white := range []bool{true, false} {
+		positionIndex = g.PositionMap(white)
+		root := g.BlackPositions
+		if white {
+			root = g.WhitePositions
+		}
+		if err := replay(root, chess.StartingPosition()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// relink restores the pointers shared between the move trees and their indexes.
+// gob encodes every pointer separately, so a decoded graph has a copy of a node for each reference to it.
+// Nodes missing from an index (all of them in version 0 graphs) are added to it
+func (g *PositionGraph) relink() {
+	if g.WhitePositionIndex == nil {
+		g.WhitePositionIndex = make(map[Key]*PositionNode)
+	}
+	if g.BlackPositionIndex == nil {
+		g.BlackPositionIndex = make(map[Key]*PositionNode)
+	}
+	for _, tree := range []struct {
+		root          *PositionNode
+		positionIndex map[Key]*PositionNode
+	}{{g.WhitePositions, g.WhitePositionIndex}, {g.BlackPositions, g.BlackPositionIndex}} {
+		relinkNode(tree.root, tree.positionIndex, map[*PositionNode]bool{tree.root: true})
+	}
+}
+
+func relinkNode(node *PositionNode, positionIndex map[Key]*PositionNode, visited map[*PositionNode]bool) {
+	if node == nil {
+		return
+	}
+	for _, move := range node.Moves {
+		if shared, found := positionIndex[move.To.Position.Key]; found {
+			move.To = shared
+		} else {
+			positionIndex[move.To.Position.Key] = move.To
+		}
+		if !visited[move.To] {
+			visited[move.To] = true
+			relinkNode(move.To, positionIndex, visited)
+		}
+	}
+}
+
+// computeKeys sets the keys of the positions of a graph decoded from a file that had no keys.
+// The trees are not relinked yet, so every copy of a position is keyed
+func (g *PositionGraph) computeKeys() error {
+	keys := make(map[FEN]Key)
+	var compute func(node *PositionNode) error
+	compute = func(node *PositionNode) error {
+		for _, move := range node.Moves {
+			position := move.To.Position
+			key, found := keys[position.FEN]
+			if !found {
+				var err error
+				if key, err = position.FEN.Key(); err != nil {
+					return err
+				}
+				keys[position.FEN] = key
+			}
+			position.Key = key
+			if err := compute(move.To); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, root := range []*PositionNode{g.WhitePositions, g.BlackPositions} {
+		if root == nil {
+			continue
+		}
+		root.Position.Key = NewKey(chess.StartingPosition())
+		if err := compute(root); err != nil {
+			return err
+		}
+	}
+	return nil
 }
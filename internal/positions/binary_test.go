@@ -1,7 +1,10 @@
 package positions
 
 import (
+	"encoding/gob"
 	"fmt"
+	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
@@ -34,11 +37,235 @@ func TestGraphBinary(t *testing.T) {
 	if err := graph.AddGame(userGame); err != nil {
 		t.Errorf("%v", err)
 	}
-	if err = DumpGraph(graph, "../../../graph.bin"); err != nil {
+	path := filepath.Join(t.TempDir(), "graph.bin")
+	if err = DumpGraph(graph, path); err != nil {
 		t.Errorf("could not dump a PositionGraph: %v", err)
 		return
 	}
-	if newGraph, err := LoadGraph("../../../graph.bin"); err != nil || !reflect.DeepEqual(newGraph, graph) {
+	if newGraph, err := LoadGraph(path); err != nil || !reflect.DeepEqual(newGraph, graph) {
 		t.Errorf("could not load a PositionGraph - error: %v", err)
 	}
+
+	newGraph, err := LoadGraph(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, move := range newGraph.WhitePositions.Moves {
+		if newGraph.WhitePositionIndex[move.To.Position.Key] != move.To {
+			t.Errorf("expected the nodes of a loaded graph to be shared with the index")
+		}
+	}
+}
+
+func TestDumpGraph_Permissions(t *testing.T) {
+	graph, _ := NewPositionGraph(2)
+	path := filepath.Join(t.TempDir(), "graph.bin")
+	if err := DumpGraph(graph, path); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0644 {
+		t.Errorf("expected a new graph to be created with 0644, got %v", info.Mode())
+	}
+	if err = os.Chmod(path, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := DumpGraph(graph, path); err != nil {
+		t.Fatal(err)
+	}
+	info, err = os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected a replaced graph to keep 0600, got %v", info.Mode())
+	}
+}
+
+// graphV0, nodeV0, positionV0 and moveV0 are the layout of a graph before the colours got separate indexes
+// and before the games were counted
+type graphV0 struct {
+	Depth          int
+	WhitePositions *nodeV0
+	BlackPositions *nodeV0
+	PositionMap    map[FEN]*nodeV0
+}
+
+type nodeV0 struct {
+	Position   *positionV0
+	LastPlayed time.Time
+	Moves      []*moveV0
+}
+
+type positionV0 struct {
+	FEN       FEN
+	Score     float32
+	Evaluated bool
+}
+
+type moveV0 struct {
+	To   *nodeV0
+	Move string
+}
+
+func TestLoadGraph_Migration(t *testing.T) {
+	newNode := func(fen FEN) *nodeV0 {
+		return &nodeV0{Position: &positionV0{FEN: fen}}
+	}
+	const (
+		e4FEN = "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq"
+		e5FEN = "rnbqkbnr/pppp1ppp/8/4p3/4P3/8/PPPP1PPP/RNBQKBNR w KQkq"
+		c5FEN = "rnbqkbnr/pp1ppppp/8/2p5/4P3/8/PPPP1PPP/RNBQKBNR w KQkq"
+	)
+	// a white game 1. e4 e5 and a black game 1. e4 c5 used to share the node after 1. e4
+	e4, e5, c5 := newNode(e4FEN), newNode(e5FEN), newNode(c5FEN)
+	// LastPlayed used to be the date of the first game
+	firstGame := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	e4.LastPlayed = firstGame
+	e4.Moves = []*moveV0{{To: e5, Move: "e5"}, {To: c5, Move: "c5"}}
+	start := FEN(chess.StartingPosition().String())
+	old := graphV0{
+		Depth:          2,
+		WhitePositions: &nodeV0{Position: &positionV0{FEN: start, Evaluated: true}, Moves: []*moveV0{{To: e4, Move: "e4"}}},
+		BlackPositions: &nodeV0{Position: &positionV0{FEN: start, Evaluated: true}, Moves: []*moveV0{{To: e4, Move: "e4"}}},
+		PositionMap:    map[FEN]*nodeV0{e4FEN: e4, e5FEN: e5, c5FEN: c5},
+	}
+	path := filepath.Join(t.TempDir(), "graph_v0.bin")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = gob.NewEncoder(file).Encode(old)
+	file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	graph, err := LoadGraph(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if graph.WhitePositions.Position.Evaluated {
+		t.Errorf("expected the starting position not to be evaluated")
+	}
+	if graph.Version != GraphVersion || len(graph.WhitePositionIndex) != 3 || len(graph.BlackPositionIndex) != 3 {
+		t.Errorf("expected both colours to be indexed, got version %v with %v white and %v black positions",
+			graph.Version, len(graph.WhitePositionIndex), len(graph.BlackPositionIndex))
+	}
+	e4Key, err := FEN(e4FEN).Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+	white, black := graph.WhitePositionIndex[e4Key], graph.BlackPositionIndex[e4Key]
+	if white == black || graph.WhitePositions.Moves[0].To != white || graph.BlackPositions.Moves[0].To != black {
+		t.Errorf("expected separate nodes after 1. e4 in each repertoire")
+	}
+	// the games were not counted, so every move counts as played once
+	if played := graph.WhitePositions.Moves[0].Played; played != 1 || white.Moves[0].Played != 1 || black.Moves[1].Played != 1 {
+		t.Errorf("expected the migrated moves to be played once, got %v", played)
+	}
+	if !white.FirstPlayed.Equal(firstGame) || !black.LastPlayed.Equal(firstGame) {
+		t.Errorf("expected the date of the first game to be kept, got %v - %v", white.FirstPlayed, white.LastPlayed)
+	}
+	if err = graph.AddGame(fetching.UserGame{Moves: []string{"d4"}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(graph.WhitePositions.Moves) != 1 || len(graph.BlackPositions.Moves) != 2 {
+		t.Errorf("expected a black game to change the black repertoire only")
+	}
+	if err = graph.AddGame(fetching.UserGame{White: true, Moves: []string{"e4", "e5"}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(graph.WhitePositionIndex) != 3 || graph.WhitePositions.Moves[0].Played != 2 {
+		t.Errorf("expected a new game to reach the migrated positions, got %v white positions", len(graph.WhitePositionIndex))
+	}
+}
+
+func TestLoadGraph_RootPlaceholder(t *testing.T) {
+	graph, _ := NewPositionGraph(2)
+	if err := graph.AddGame(fetching.UserGame{White: true, Moves: []string{"e4", "e5"}}); err != nil {
+		t.Fatal(err)
+	}
+	// version 4 graphs marked the starting positions as evaluated at 0.00
+	graph.WhitePositions.Position.Evaluated = true
+	graph.WhitePositions.Position.Evaluation = Evaluation{WhiteToMove: true}
+	graph.BlackPositions.Position.Evaluated = true
+	graph.BlackPositions.Position.Evaluation = Evaluation{WhiteToMove: true, Centipawns: 25, Depth: 20, Source: "stockfish"}
+	compact := graph.Compact()
+	compact.Version = 4
+	data, err := compact.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "graph_v4.bin")
+	if err = os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadGraph(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.WhitePositions.Position.Evaluated {
+		t.Errorf("expected the placeholder evaluation of the starting position to be dropped")
+	}
+	if e := loaded.BlackPositions.Position.Evaluation; !loaded.BlackPositions.Position.Evaluated || e.Centipawns != 25 {
+		t.Errorf("expected the engine's evaluation of the starting position to be kept, got %+v", e)
+	}
+}
+
+func TestLoadGraph_EnPassant(t *testing.T) {
+	graph, _ := NewPositionGraph(4)
+	if err := graph.AddGame(fetching.UserGame{White: true, Moves: []string{"e4", "Nf6", "e5", "d5"}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, node := range graph.WhitePositionIndex {
+		node.Position.Evaluated = true
+		node.Position.Evaluation = Evaluation{WhiteToMove: node.Position.FEN.WhiteToMove(), Centipawns: 30, Depth: 20}
+	}
+	// version 6 graphs dropped the en passant square, and the keys of graphs migrated from version 2 dropped the file
+	d5 := graph.FindLine(true, []string{"e4", "Nf6", "e5", "d5"})
+	fen, key := d5.Position.FEN, d5.Position.Key
+	d5.Position.FEN = FEN(strings.TrimSuffix(string(fen), " d6"))
+	oldKey, err := d5.Position.FEN.Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+	delete(graph.WhitePositionIndex, key)
+	d5.Position.Key = oldKey
+	graph.WhitePositionIndex[oldKey] = d5
+	compact := graph.Compact()
+	compact.Version = 6
+	data, err := compact.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "graph_v6.bin")
+	if err = os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadGraph(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrated := loaded.FindLine(true, []string{"e4", "Nf6", "e5", "d5"})
+	if migrated.Position.FEN != fen || migrated.Position.Evaluated {
+		t.Errorf("expected the en passant square to be restored and the evaluation to be dropped, got %v", migrated.Position)
+	}
+	if e5 := loaded.FindLine(true, []string{"e4", "Nf6", "e5"}); !e5.Position.Evaluated {
+		t.Errorf("expected the evaluations of the other positions to be kept")
+	}
+	if _, found := loaded.WhitePositionIndex[oldKey]; found || migrated.Position.Key != key || loaded.WhitePositionIndex[key] != migrated {
+		t.Errorf("expected the position to be re-keyed, got %v", migrated.Position.Key)
+	}
+	// new games reach the migrated node
+	size := len(loaded.WhitePositionIndex)
+	if err = loaded.AddGame(fetching.UserGame{White: true, Moves: []string{"e4", "Nf6", "e5", "d5", "exd6"}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.WhitePositionIndex) != size+1 || len(migrated.Moves) != 1 || migrated.Moves[0].Played != 1 {
+		t.Errorf("expected the game to continue from the migrated position, got %v positions", len(loaded.WhitePositionIndex))
+	}
 }
@@ -0,0 +1,77 @@
+package positions
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/Hofsiedge/ChessOpeningAnalyzer/internal/fetching"
+)
+
+// BuildGraph builds a graph of the given depth from the games of the stream using several workers at once.
+// AddGame is not safe for concurrent use, so each worker adds the games it reads to a partial graph of its own
+// and the partial graphs are merged when the stream is closed. The moves of a position may therefore be
+// in a different order than AddStream would put them in. Errors are handled as by AddStream, and onError
+// is never called concurrently. After the first error returned by onError the workers stop reading
+// the stream, so the caller should cancel it. BuildGraph returns the graph and the number of added games
+func BuildGraph(depth, workers int, stream <-chan fetching.StreamedGame, onError func(error) error) (*PositionGraph, int, error) {
+	if workers < 1 {
+		workers = 1
+	}
+	graphs := make([]*PositionGraph, workers)
+	for i := range graphs {
+		var err error
+		if graphs[i], err = NewPositionGraph(depth); err != nil {
+			return nil, 0, fmt.Errorf("positions.BuildGraph: %w", err)
+		}
+	}
+
+	// the first error returned by onError stops all the workers
+	var (
+		mu      sync.Mutex
+		stopErr error
+	)
+	done := make(chan struct{})
+	handle := func(err error) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if stopErr != nil {
+			return stopErr
+		}
+		if onError != nil {
+			err = onError(err)
+		}
+		if err != nil {
+			stopErr = err
+			close(done)
+		}
+		return err
+	}
+	added := make([]int, workers)
+	wg := sync.WaitGroup{}
+	wg.Add(workers)
+	for i := range graphs {
+		go func(i int) {
+			defer wg.Done()
+			added[i], _ = graphs[i].addStream(done, stream, handle)
+		}(i)
+	}
+	wg.Wait()
+
+	total := 0
+	for _, n := range added {
+		total += n
+	}
+	if stopErr != nil {
+		return nil, total, stopErr
+	}
+	if workers == 1 {
+		return graphs[0], total, nil
+	}
+	// the partial graphs have the same depth and the evaluations of a stream come from its platform,
+	// so Merge reports no conflicts
+	graph, _, err := Merge(graphs...)
+	if err != nil {
+		return nil, total, fmt.Errorf("positions.BuildGraph: %w", err)
+	}
+	return graph, total, nil
+}
@@ -0,0 +1,108 @@
+package positions
+
+import (
+	"errors"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/Hofsiedge/ChessOpeningAnalyzer/internal/fetching"
+)
+
+// streamGames returns a closed stream of the games followed by the errors
+func streamGames(games []fetching.UserGame, errs ...error) <-chan fetching.StreamedGame {
+	stream := make(chan fetching.StreamedGame, len(games)+len(errs))
+	for i := range games {
+		stream <- fetching.StreamedGame{Game: &games[i]}
+	}
+	for _, err := range errs {
+		stream <- fetching.StreamedGame{Err: err}
+	}
+	close(stream)
+	return stream
+}
+
+// moveStats maps the moves of each repertoire's positions to their statistics, ignoring the order of the moves
+func moveStats(g *PositionGraph) map[bool]map[Key]map[string][4]int {
+	stats := make(map[bool]map[Key]map[string][4]int)
+	for _, white := range []bool{true, false} {
+		stats[white] = make(map[Key]map[string][4]int)
+		for key, node := range g.PositionMap(white) {
+			moves := make(map[string][4]int, len(node.Moves))
+			for _, move := range node.Moves {
+				moves[move.Move] = [4]int{move.Played, move.Wins, move.Draws, move.Losses}
+			}
+			stats[white][key] = moves
+		}
+	}
+	return stats
+}
+
+func TestBuildGraph(t *testing.T) {
+	games := randomGames(400, 10)
+	expected, _ := NewPositionGraph(10)
+	if _, err := expected.AddStream(streamGames(games), nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, workers := range []int{1, 4} {
+		graph, added, err := BuildGraph(10, workers, streamGames(games), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if added != len(games) {
+			t.Errorf("%v workers: expected %v added games, got %v", workers, len(games), added)
+		}
+		if !reflect.DeepEqual(moveStats(graph), moveStats(expected)) {
+			t.Errorf("%v workers: expected the graph AddStream builds", workers)
+		}
+		if root, expectedRoot := graph.WhitePositions, expected.WhitePositions; !reflect.DeepEqual(root.MonthlyPlayed, expectedRoot.MonthlyPlayed) ||
+			!root.FirstPlayed.Equal(expectedRoot.FirstPlayed) || !root.LastPlayed.Equal(expectedRoot.LastPlayed) {
+			t.Errorf("%v workers: expected the play history of the games to be combined", workers)
+		}
+	}
+
+	// invalid games are passed to the handler, the first error it returns stops all the workers
+	errFetching := errors.New("fetching error")
+	invalid := append(games[:10:10], fetching.UserGame{White: true, Moves: []string{"e4", "e5", "Ke3"}})
+	handled := make([]error, 0)
+	_, _, err := BuildGraph(10, 4, streamGames(invalid, errFetching, errFetching), func(err error) error {
+		handled = append(handled, err)
+		if errors.Is(err, ErrInvalidGame) {
+			return nil
+		}
+		return err
+	})
+	if !errors.Is(err, errFetching) {
+		t.Errorf("expected \"%v\", got \"%v\"", errFetching, err)
+	}
+	// the invalid game may be read after the fetching error, but no error is handled after the one that stopped the workers
+	if last := handled[len(handled)-1]; !errors.Is(last, errFetching) || len(handled) > 2 {
+		t.Errorf("expected the handling to stop after the first fetching error, got %v", handled)
+	}
+	// without a handler the first error stops the workers
+	if _, _, err = BuildGraph(10, 4, streamGames(invalid, errFetching), nil); err == nil {
+		t.Error("expected an error without a handler")
+	}
+}
+
+// BenchmarkBuildGraph compares building a graph on a single goroutine with merging partial graphs of several workers
+func BenchmarkBuildGraph(b *testing.B) {
+	games := randomGames(2000, 16)
+	b.Run("AddStream", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			graph, _ := NewPositionGraph(16)
+			if _, err := graph.AddStream(streamGames(games), nil); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("BuildGraph", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, _, err := BuildGraph(16, runtime.NumCPU(), streamGames(games), nil); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,486 @@
+package positions
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// CompactGraph is the compact file format of a PositionGraph: nodes and moves are stored in slices
+// and refer to each other by index, move names are interned. It is only a serialization format: graphs are
+// always queried and modified as a PositionGraph, see PositionGraph.Compact and CompactGraph.Graph
+type CompactGraph struct {
+	Version int
+	Depth   int
+	// Nodes holds the white repertoire starting with its root followed by the black one starting at BlackRoot
+	Nodes     []CompactNode
+	BlackRoot int
+	Moves     []CompactMove
+	// SAN holds the distinct move names, CompactMove.SAN indexes it
+	SAN []string
+}
+
+type CompactNode struct {
+	Position      Position
+	FirstPlayed   time.Time
+	LastPlayed    time.Time
+	MonthlyPlayed map[string]int
+	Alternatives  []Evaluation
+	// Moves[FirstMove:EndMove] are the moves played from the position
+	FirstMove int
+	EndMove   int
+}
+
+type CompactMove struct {
+	// To is the index of the position the move leads to
+	To int
+	// SAN is the index of the move name
+	SAN           int
+	Played        int
+	Wins          int
+	Draws         int
+	Losses        int
+	FirstPlayed   time.Time
+	LastPlayed    time.Time
+	MonthlyPlayed map[string]int
+}
+
+// Compact converts the graph into the compact file format. Nodes are numbered depth-first in the order of the moves.
+// The monthly histories and the alternatives are shared with the graph
+func (g *PositionGraph) Compact() *CompactGraph {
+	c := &CompactGraph{Version: GraphVersion, Depth: g.Depth}
+	size := len(g.WhitePositionIndex) + len(g.BlackPositionIndex) + 2
+	indexes := make(map[*PositionNode]int, size)
+	nodes := make([]*PositionNode, 0, size)
+	var number func(node *PositionNode)
+	number = func(node *PositionNode) {
+		indexes[node] = len(nodes)
+		nodes = append(nodes, node)
+		for _, move := range node.Moves {
+			if _, found := indexes[move.To]; !found {
+				number(move.To)
+			}
+		}
+	}
+	number(g.WhitePositions)
+	c.BlackRoot = len(nodes)
+	number(g.BlackPositions)
+
+	san := make(map[string]int)
+	c.Nodes = make([]CompactNode, len(nodes))
+	for i, node := range nodes {
+		c.Nodes[i] = CompactNode{
+			Position:      *node.Position,
+			FirstPlayed:   node.FirstPlayed,
+			LastPlayed:    node.LastPlayed,
+			MonthlyPlayed: node.MonthlyPlayed,
+			Alternatives:  node.Alternatives,
+			FirstMove:     len(c.Moves),
+		}
+		for _, move := range node.Moves {
+			name, found := san[move.Move]
+			if !found {
+				name = len(c.SAN)
+				san[move.Move] = name
+				c.SAN = append(c.SAN, move.Move)
+			}
+			c.Moves = append(c.Moves, CompactMove{
+				To:            indexes[move.To],
+				SAN:           name,
+				Played:        move.Played,
+				Wins:          move.Wins,
+				Draws:         move.Draws,
+				Losses:        move.Losses,
+				FirstPlayed:   move.FirstPlayed,
+				LastPlayed:    move.LastPlayed,
+				MonthlyPlayed: move.MonthlyPlayed,
+			})
+		}
+		c.Nodes[i].EndMove = len(c.Moves)
+	}
+	return c
+}
+
+// Graph builds a PositionGraph from the compact file format. Nodes, positions and moves are allocated
+// in one slice each instead of separately, so a large graph consists of a few big objects.
+// Moves added to a loaded position later are allocated as usual
+func (c *CompactGraph) Graph() *PositionGraph {
+	nodes := make([]PositionNode, len(c.Nodes))
+	positions := make([]Position, len(c.Nodes))
+	moves := make([]Move, len(c.Moves))
+	edges := make([]*Move, len(c.Moves))
+	for i, move := range c.Moves {
+		moves[i] = Move{
+			To:            &nodes[move.To],
+			Move:          c.SAN[move.SAN],
+			Played:        move.Played,
+			Wins:          move.Wins,
+			Draws:         move.Draws,
+			Losses:        move.Losses,
+			FirstPlayed:   move.FirstPlayed,
+			LastPlayed:    move.LastPlayed,
+			MonthlyPlayed: move.MonthlyPlayed,
+		}
+		edges[i] = &moves[i]
+	}
+	graph := &PositionGraph{
+		Version:            c.Version,
+		Depth:              c.Depth,
+		WhitePositions:     &nodes[0],
+		BlackPositions:     &nodes[c.BlackRoot],
+		WhitePositionIndex: make(map[Key]*PositionNode, c.BlackRoot),
+		BlackPositionIndex: make(map[Key]*PositionNode, len(c.Nodes)-c.BlackRoot),
+	}
+	for i, node := range c.Nodes {
+		positions[i] = node.Position
+		nodes[i] = PositionNode{
+			Position:      &positions[i],
+			FirstPlayed:   node.FirstPlayed,
+			LastPlayed:    node.LastPlayed,
+			MonthlyPlayed: node.MonthlyPlayed,
+			Alternatives:  node.Alternatives,
+		}
+		if node.FirstMove != node.EndMove {
+			// the capacity is limited for AddGame not to overwrite the moves of the next node
+			nodes[i].Moves = edges[node.FirstMove:node.EndMove:node.EndMove]
+		}
+		switch {
+		case i == 0 || i == c.BlackRoot:
+		case i < c.BlackRoot:
+			graph.WhitePositionIndex[node.Position.Key] = &nodes[i]
+		default:
+			graph.BlackPositionIndex[node.Position.Key] = &nodes[i]
+		}
+	}
+	return graph
+}
+
+// compactMagic starts every file of CompactGraph, older graph files are gob encoded
+const compactMagic = "COAGRAPH"
+
+// ErrCorruptGraph is returned when the data of a CompactGraph is truncated or inconsistent
+var ErrCorruptGraph = errors.New("corrupt position graph")
+
+// MarshalBinary implements encoding.BinaryMarshaler interface. Numbers are varint encoded.
+// The output only depends on the graph, so saving the same graph twice gives identical files
+func (c *CompactGraph) MarshalBinary() ([]byte, error) {
+	e := encoder{buf: make([]byte, 0, 64*len(c.Nodes)+32*len(c.Moves))}
+	e.buf = append(e.buf, compactMagic...)
+	e.int(c.Version)
+	e.int(c.Depth)
+	e.int(c.BlackRoot)
+	e.int(len(c.SAN))
+	for _, san := range c.SAN {
+		e.string(san)
+	}
+	e.int(len(c.Nodes))
+	for i := range c.Nodes {
+		node := &c.Nodes[i]
+		e.position(&node.Position)
+		e.time(node.FirstPlayed)
+		e.time(node.LastPlayed)
+		e.history(node.MonthlyPlayed)
+		e.int(len(node.Alternatives))
+		for j := range node.Alternatives {
+			e.evaluation(&node.Alternatives[j])
+		}
+		e.int(node.EndMove - node.FirstMove)
+	}
+	e.int(len(c.Moves))
+	for i := range c.Moves {
+		move := &c.Moves[i]
+		e.int(move.To)
+		e.int(move.SAN)
+		e.int(move.Played)
+		e.int(move.Wins)
+		e.int(move.Draws)
+		e.int(move.Losses)
+		e.time(move.FirstPlayed)
+		e.time(move.LastPlayed)
+		if c.Version >= 6 {
+			e.history(move.MonthlyPlayed)
+		}
+	}
+	return e.buf, e.err
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler interface.
+// Strings of the graph share the memory of a single copy of data
+func (c *CompactGraph) UnmarshalBinary(data []byte) error {
+	if len(data) < len(compactMagic) || string(data[:len(compactMagic)]) != compactMagic {
+		return fmt.Errorf("%w: not a compact graph", ErrCorruptGraph)
+	}
+	d := decoder{data: data, text: string(data), pos: len(compactMagic)}
+	c.Version = d.int()
+	if c.Version > GraphVersion {
+		return fmt.Errorf("%w: unsupported version %v", ErrCorruptGraph, c.Version)
+	}
+	c.Depth = d.int()
+	c.BlackRoot = d.int()
+	c.SAN = make([]string, d.length())
+	for i := range c.SAN {
+		c.SAN[i] = d.string()
+	}
+	c.Nodes = make([]CompactNode, d.length())
+	moves := 0
+	for i := range c.Nodes {
+		node := &c.Nodes[i]
+		d.position(&node.Position)
+		node.FirstPlayed = d.time()
+		node.LastPlayed = d.time()
+		node.MonthlyPlayed = d.history()
+		if alternatives := d.length(); alternatives > 0 {
+			node.Alternatives = make([]Evaluation, alternatives)
+			for j := range node.Alternatives {
+				d.evaluation(&node.Alternatives[j])
+			}
+		}
+		node.FirstMove = moves
+		moves += d.length()
+		node.EndMove = moves
+	}
+	c.Moves = make([]CompactMove, d.length())
+	for i := range c.Moves {
+		move := &c.Moves[i]
+		move.To = d.int()
+		move.SAN = d.int()
+		move.Played = d.int()
+		move.Wins = d.int()
+		move.Draws = d.int()
+		move.Losses = d.int()
+		move.FirstPlayed = d.time()
+		move.LastPlayed = d.time()
+		if c.Version >= 6 {
+			// the moves had no monthly history before
+			move.MonthlyPlayed = d.history()
+		}
+		if d.err == nil && (move.To < 0 || move.To >= len(c.Nodes) || move.SAN < 0 || move.SAN >= len(c.SAN)) {
+			d.err = fmt.Errorf("%w: move %v is out of range", ErrCorruptGraph, i)
+		}
+	}
+	if d.err != nil {
+		return d.err
+	}
+	if len(c.Nodes) == 0 || c.BlackRoot <= 0 || c.BlackRoot >= len(c.Nodes) || moves != len(c.Moves) {
+		return fmt.Errorf("%w: inconsistent sizes", ErrCorruptGraph)
+	}
+	return nil
+}
+
+type encoder struct {
+	buf     []byte
+	scratch [binary.MaxVarintLen64]byte
+	err     error
+}
+
+func (e *encoder) int(v int) {
+	e.int64(int64(v))
+}
+
+func (e *encoder) int64(v int64) {
+	n := binary.PutVarint(e.scratch[:], v)
+	e.buf = append(e.buf, e.scratch[:n]...)
+}
+
+func (e *encoder) bool(v bool) {
+	if v {
+		e.buf = append(e.buf, 1)
+	} else {
+		e.buf = append(e.buf, 0)
+	}
+}
+
+func (e *encoder) string(s string) {
+	e.int(len(s))
+	e.buf = append(e.buf, s...)
+}
+
+// time encodes zero times as an empty string, the others with time.Time.MarshalBinary
+func (e *encoder) time(t time.Time) {
+	if t.IsZero() {
+		e.int(0)
+		return
+	}
+	data, err := t.MarshalBinary()
+	if err != nil && e.err == nil {
+		e.err = err
+	}
+	e.string(string(data))
+}
+
+// history encodes a monthly history as its length followed by months and counts in the order of the months
+func (e *encoder) history(monthly map[string]int) {
+	months := make([]string, 0, len(monthly))
+	for month := range monthly {
+		months = append(months, month)
+	}
+	sort.Strings(months)
+	e.int(len(months))
+	for _, month := range months {
+		e.string(month)
+		e.int(monthly[month])
+	}
+}
+
+func (e *encoder) position(p *Position) {
+	e.string(string(p.FEN))
+	binary.BigEndian.PutUint64(e.scratch[:], uint64(p.Key))
+	e.buf = append(e.buf, e.scratch[:8]...)
+	e.bool(p.Evaluated)
+	e.evaluation(&p.Evaluation)
+}
+
+func (e *encoder) evaluation(ev *Evaluation) {
+	e.int(int(ev.Perspective))
+	e.bool(ev.WhiteToMove)
+	e.int(ev.Centipawns)
+	e.bool(ev.Mate)
+	e.int(ev.MateIn)
+	e.int(ev.Depth)
+	e.int64(ev.Nodes)
+	e.bool(ev.WDL != nil)
+	if ev.WDL != nil {
+		e.int(ev.WDL.Win)
+		e.int(ev.WDL.Draw)
+		e.int(ev.WDL.Loss)
+	}
+	e.string(ev.BestMove)
+	e.int(len(ev.PV))
+	for _, move := range ev.PV {
+		e.string(move)
+	}
+	e.string(ev.Source)
+}
+
+// decoder reads the data of an encoder. The first error is kept and the following reads return zero values
+type decoder struct {
+	data []byte
+	// text is data converted once, strings are sliced from it
+	text string
+	pos  int
+	err  error
+}
+
+func (d *decoder) fail() {
+	if d.err == nil {
+		d.err = fmt.Errorf("%w: unexpected end of data at %v", ErrCorruptGraph, d.pos)
+	}
+}
+
+func (d *decoder) int() int {
+	return int(d.int64())
+}
+
+func (d *decoder) int64() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.data[d.pos:])
+	if n <= 0 {
+		d.fail()
+		return 0
+	}
+	d.pos += n
+	return v
+}
+
+// length reads a number of elements that follow, each of them takes at least a byte
+func (d *decoder) length() int {
+	n := d.int()
+	if n < 0 || n > len(d.data)-d.pos {
+		d.fail()
+		return 0
+	}
+	return n
+}
+
+func (d *decoder) bool() bool {
+	if d.err != nil {
+		return false
+	}
+	if d.pos >= len(d.data) {
+		d.fail()
+		return false
+	}
+	d.pos++
+	return d.data[d.pos-1] != 0
+}
+
+func (d *decoder) bytes() []byte {
+	n := d.length()
+	if d.err != nil {
+		return nil
+	}
+	d.pos += n
+	return d.data[d.pos-n : d.pos]
+}
+
+func (d *decoder) string() string {
+	n := d.length()
+	if d.err != nil {
+		return ""
+	}
+	d.pos += n
+	return d.text[d.pos-n : d.pos]
+}
+
+func (d *decoder) time() time.Time {
+	var t time.Time
+	if data := d.bytes(); len(data) > 0 {
+		if err := t.UnmarshalBinary(data); err != nil && d.err == nil {
+			d.err = fmt.Errorf("%w: %v", ErrCorruptGraph, err)
+		}
+	}
+	return t
+}
+
+// history decodes a monthly history, an empty one is nil
+func (d *decoder) history() map[string]int {
+	months := d.length()
+	if months == 0 {
+		return nil
+	}
+	monthly := make(map[string]int, months)
+	for i := 0; i < months; i++ {
+		month := d.string()
+		monthly[month] = d.int()
+	}
+	return monthly
+}
+
+func (d *decoder) position(p *Position) {
+	p.FEN = FEN(d.string())
+	if d.err == nil && d.pos+8 > len(d.data) {
+		d.fail()
+	}
+	if d.err != nil {
+		return
+	}
+	p.Key = Key(binary.BigEndian.Uint64(d.data[d.pos:]))
+	d.pos += 8
+	p.Evaluated = d.bool()
+	d.evaluation(&p.Evaluation)
+}
+
+func (d *decoder) evaluation(ev *Evaluation) {
+	ev.Perspective = Perspective(d.int())
+	ev.WhiteToMove = d.bool()
+	ev.Centipawns = d.int()
+	ev.Mate = d.bool()
+	ev.MateIn = d.int()
+	ev.Depth = d.int()
+	ev.Nodes = d.int64()
+	if d.bool() {
+		ev.WDL = &WDL{Win: d.int(), Draw: d.int(), Loss: d.int()}
+	}
+	ev.BestMove = d.string()
+	if moves := d.length(); moves > 0 {
+		ev.PV = make([]string, moves)
+		for i := range ev.PV {
+			ev.PV[i] = d.string()
+		}
+	}
+	ev.Source = d.string()
+}
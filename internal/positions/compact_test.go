@@ -0,0 +1,201 @@
+package positions
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Hofsiedge/ChessOpeningAnalyzer/internal/fetching"
+	"github.com/notnil/chess"
+)
+
+func newCompactGraph(t *testing.T) *PositionGraph {
+	graph, _ := NewPositionGraph(6)
+	for i, game := range []struct {
+		white bool
+		moves string
+	}{
+		{true, "e4 e5 Nf3 Nc6 Bb5 a6"},
+		{true, "Nf3 Nc6 e4 e5 Bb5 Nf6"},
+		{false, "d4 Nf6 c4 e6"},
+		{false, "e4 e5 Nf3 Nc6"},
+	} {
+		err := graph.AddGame(fetching.UserGame{
+			White:   game.white,
+			EndTime: time.Date(2021, time.Month(i+1), 1, 0, 0, 0, 0, time.UTC),
+			Result:  fetching.Win,
+			Moves:   strings.Split(game.moves, " "),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	e5 := graph.WhitePositions.Moves[0].To.Moves[0].To
+	e5.Position.Evaluated = true
+	e5.Position.Evaluation = Evaluation{
+		Perspective: SideToMovePerspective,
+		WhiteToMove: true,
+		Centipawns:  -35,
+		Depth:       20,
+		Nodes:       1 << 40,
+		WDL:         &WDL{Win: 50, Draw: 900, Loss: 50},
+		BestMove:    "Nf3",
+		PV:          []string{"Nf3", "Nc6", "Bb5"},
+		Source:      "stockfish",
+	}
+	e5.Alternatives = []Evaluation{e5.Position.Evaluation, {Mate: true, MateIn: -3, BestMove: "Qh5"}}
+	return graph
+}
+
+func TestCompactGraph(t *testing.T) {
+	graph := newCompactGraph(t)
+	compact := graph.Compact()
+	if compact.BlackRoot != len(graph.WhitePositionIndex)+1 || len(compact.Nodes) != len(graph.Nodes())+2 {
+		t.Errorf("expected %v white and %v black nodes, got %v with the black root at %v",
+			len(graph.WhitePositionIndex)+1, len(graph.BlackPositionIndex)+1, len(compact.Nodes), compact.BlackRoot)
+	}
+	if len(compact.SAN) != 10 {
+		t.Errorf("expected 10 distinct moves, got %v", compact.SAN)
+	}
+	data, err := compact.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the monthly histories are maps, their order must not leak into the file
+	for i := 0; i < 10; i++ {
+		if again, _ := graph.Compact().MarshalBinary(); !bytes.Equal(again, data) {
+			t.Fatalf("expected the same graph to be encoded into the same bytes")
+		}
+	}
+	decoded := new(CompactGraph)
+	if err = decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	loaded := decoded.Graph()
+	if !reflect.DeepEqual(loaded, graph) {
+		t.Errorf("expected the graph to survive the compact representation")
+	}
+
+	// transpositions share the node after 1. e4 e5 2. Nf3 Nc6
+	whiteNc6 := loaded.FindLine(true, []string{"e4", "e5", "Nf3", "Nc6"})
+	if whiteNc6 == nil || loaded.FindLine(true, []string{"Nf3", "Nc6", "e4", "e5"}) != whiteNc6 {
+		t.Errorf("expected the transposition to lead to the same node")
+	}
+	// a new move must not overwrite the moves of the neighbouring nodes in the shared slice
+	blackE5 := loaded.FindLine(false, []string{"e4", "e5"})
+	if err = loaded.AddGame(fetching.UserGame{White: true, Moves: []string{"e4", "e5", "Bc4"}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(blackE5.Moves) != 1 || blackE5.Moves[0].Move != "Nf3" || len(whiteNc6.Moves) != 1 || whiteNc6.Moves[0].Move != "Bb5" {
+		t.Errorf("expected the moves of other nodes to be kept, got %v and %v", blackE5.Moves, whiteNc6.Moves)
+	}
+
+	for _, n := range []int{0, 5, len(data) / 2, len(data) - 1} {
+		if err := new(CompactGraph).UnmarshalBinary(data[:n]); !errors.Is(err, ErrCorruptGraph) {
+			t.Errorf("expected \"%v\" error for %v bytes, got \"%v\"", ErrCorruptGraph, n, err)
+		}
+	}
+}
+
+// randomGames generates games that pick one of the first forward moves at random.
+// Pieces never move back, so the games never repeat a position, which gob could not encode
+func randomGames(games, depth int) []fetching.UserGame {
+	random := rand.New(rand.NewSource(1))
+	start := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	result := make([]fetching.UserGame, games)
+	for i := range result {
+		pos := chess.StartingPosition()
+		moves := make([]string, 0, depth)
+		for len(moves) < depth {
+			forward := make([]*chess.Move, 0)
+			for _, move := range pos.ValidMoves() {
+				from, to := move.S1().Rank(), move.S2().Rank()
+				if (pos.Turn() == chess.White && to > from) || (pos.Turn() == chess.Black && to < from) {
+					forward = append(forward, move)
+				}
+			}
+			if len(forward) == 0 {
+				break
+			}
+			move := forward[random.Intn(1+len(forward)/8)]
+			moves = append(moves, chess.AlgebraicNotation{}.Encode(pos, move))
+			pos = pos.Update(move)
+		}
+		result[i] = fetching.UserGame{
+			White:   i%2 == 0,
+			EndTime: start.Add(time.Duration(i) * time.Hour),
+			Result:  fetching.Result(random.Intn(3)),
+			Moves:   moves,
+		}
+	}
+	return result
+}
+
+// randomGraph builds a graph of randomGames
+func randomGraph(tb testing.TB, games, depth int) *PositionGraph {
+	graph, _ := NewPositionGraph(depth)
+	for _, game := range randomGames(games, depth) {
+		if err := graph.AddGame(game); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	return graph
+}
+
+// BenchmarkGraphBinary compares the gob encoding of older graph files with CompactGraph
+func BenchmarkGraphBinary(b *testing.B) {
+	graph := randomGraph(b, 2000, 16)
+	b.Logf("%v positions", len(graph.Nodes()))
+	var gobData bytes.Buffer
+	if err := gob.NewEncoder(&gobData).Encode(graph); err != nil {
+		b.Fatal(err)
+	}
+	compactData, err := graph.Compact().MarshalBinary()
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Run("GobEncode", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var buffer bytes.Buffer
+			if err := gob.NewEncoder(&buffer).Encode(graph); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.ReportMetric(float64(gobData.Len()), "bytes")
+	})
+	b.Run("CompactEncode", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := graph.Compact().MarshalBinary(); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.ReportMetric(float64(len(compactData)), "bytes")
+	})
+	b.Run("GobDecode", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			decoded := new(PositionGraph)
+			if err := gob.NewDecoder(bytes.NewReader(gobData.Bytes())).Decode(decoded); err != nil {
+				b.Fatal(err)
+			}
+			decoded.relink()
+		}
+	})
+	b.Run("CompactDecode", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			decoded := new(CompactGraph)
+			if err := decoded.UnmarshalBinary(compactData); err != nil {
+				b.Fatal(err)
+			}
+			decoded.Graph()
+		}
+	})
+}
@@ -0,0 +1,227 @@
+package positions
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// DiffKind tells whether a move is present in the old graph, the new one or both of them
+type DiffKind uint8
+
+const (
+	// Kept moves are present in both graphs
+	Kept DiffKind = iota
+	// Added moves are present in the new graph only
+	Added
+	// Removed moves are present in the old graph only
+	Removed
+)
+
+// DiffOptions control which changes of the kept moves are reported by Diff
+type DiffOptions struct {
+	// Frequency is the minimal change of the share of the games played from a position
+	// that went on with the move, 0.2 means 20 percentage points
+	Frequency float64
+	// Score is the minimal change of the user's score after the move (see Move.Score)
+	Score float64
+	// MinGames is the minimal number of games with a known result in each graph to compare the scores
+	MinGames int
+}
+
+// DefaultDiffOptions are used by the diff command unless overridden with flags
+var DefaultDiffOptions = DiffOptions{
+	Frequency: 0.2,
+	Score:     0.2,
+	MinGames:  3,
+}
+
+// MoveDiff is a move of either graph compared by Diff
+type MoveDiff struct {
+	Move string
+	Kind DiffKind
+	// Old and New are the move in the old and the new graphs, nil if it is missing in one of them
+	Old *Move
+	New *Move
+	// OldShare and NewShare are the shares of the games played from the position that went on with the move
+	OldShare float64
+	NewShare float64
+	// FrequencyChanged and ScoreChanged report the kept moves that changed more than DiffOptions allow
+	FrequencyChanged bool
+	ScoreChanged     bool
+	// Transposition is set if the position the move leads to is compared under another move order
+	Transposition bool
+	// Moves are the compared moves of the next position, only the lines that changed are kept
+	Moves []*MoveDiff
+}
+
+// Changed reports whether the move itself (not the moves after it) changed
+func (d *MoveDiff) Changed() bool {
+	return d.Kind != Kept || d.FrequencyChanged || d.ScoreChanged
+}
+
+// GraphDiff is the result of Diff: the lines of each repertoire that changed between two graphs
+type GraphDiff struct {
+	White []*MoveDiff
+	Black []*MoveDiff
+}
+
+// Diff compares the repertoires of two graphs. Positions are matched by Key, so
+// a position reached by different move orders is compared once, under the first of them.
+// Lines that appeared or disappeared are reported completely, kept moves are reported if the share of games
+// they were played in or the user's score after them changed by opts.Frequency or opts.Score.
+// The moves leading to a reported one are kept to show where it is played
+func Diff(before, after *PositionGraph, opts DiffOptions) *GraphDiff {
+	return &GraphDiff{
+		White: diffNodes(before.WhitePositions, after.WhitePositions, opts, map[Key]bool{}),
+		Black: diffNodes(before.BlackPositions, after.BlackPositions, opts, map[Key]bool{}),
+	}
+}
+
+// playedFrom returns the number of games that went on from the node. Missing nodes have none
+func playedFrom(n *PositionNode) int {
+	if n == nil {
+		return 0
+	}
+	played := 0
+	for _, move := range n.Moves {
+		played += move.Played
+	}
+	return played
+}
+
+// share returns the share of total the move was played in, zero for a missing move
+func share(m *Move, total int) float64 {
+	if m == nil || total == 0 {
+		return 0
+	}
+	return float64(m.Played) / float64(total)
+}
+
+// knownResults returns the number of games with a known result played through the move
+func knownResults(m *Move) int {
+	return m.Wins + m.Draws + m.Losses
+}
+
+// diffNodes compares the moves of the same position in the old and the new graphs, either node may be nil
+func diffNodes(before, after *PositionNode, opts DiffOptions, visited map[Key]bool) []*MoveDiff {
+	diffs := make([]*MoveDiff, 0)
+	byMove := make(map[string]*MoveDiff)
+	if after != nil {
+		for _, move := range after.Moves {
+			diff := &MoveDiff{Move: move.Move, Kind: Added, New: move}
+			byMove[move.Move] = diff
+			diffs = append(diffs, diff)
+		}
+	}
+	if before != nil {
+		for _, move := range before.Moves {
+			if diff, found := byMove[move.Move]; found {
+				diff.Kind = Kept
+				diff.Old = move
+				continue
+			}
+			diffs = append(diffs, &MoveDiff{Move: move.Move, Kind: Removed, Old: move})
+		}
+	}
+	oldPlayed, newPlayed := playedFrom(before), playedFrom(after)
+	changed := make([]*MoveDiff, 0, len(diffs))
+	for _, diff := range diffs {
+		diff.OldShare, diff.NewShare = share(diff.Old, oldPlayed), share(diff.New, newPlayed)
+		if diff.Kind == Kept {
+			diff.FrequencyChanged = math.Abs(diff.NewShare-diff.OldShare) >= opts.Frequency
+			diff.ScoreChanged = knownResults(diff.Old) >= opts.MinGames && knownResults(diff.New) >= opts.MinGames &&
+				math.Abs(diff.New.Score()-diff.Old.Score()) >= opts.Score
+		}
+		var oldNext, newNext *PositionNode
+		if diff.Old != nil {
+			oldNext = diff.Old.To
+		}
+		if diff.New != nil {
+			newNext = diff.New.To
+		}
+		fen := diff.position()
+		if visited[fen] {
+			diff.Transposition = true
+		} else {
+			visited[fen] = true
+			diff.Moves = diffNodes(oldNext, newNext, opts, visited)
+		}
+		if diff.Changed() || len(diff.Moves) > 0 {
+			changed = append(changed, diff)
+		}
+	}
+	return changed
+}
+
+// position returns the key of the position the move leads to
+func (d *MoveDiff) position() Key {
+	if d.New != nil {
+		return d.New.To.Position.Key
+	}
+	return d.Old.To.Position.Key
+}
+
+// String implements fmt.Stringer interface: `+ e4 [3 games, +2 =0 -1]`, `~ e4 (played 20% -> 60%)`
+func (d *MoveDiff) String() string {
+	switch d.Kind {
+	case Added:
+		return fmt.Sprintf("+ %v%v", d.Move, formatStats(d.New))
+	case Removed:
+		return fmt.Sprintf("- %v%v", d.Move, formatStats(d.Old))
+	}
+	if !d.Changed() {
+		return d.Move
+	}
+	changes := make([]string, 0, 2)
+	if d.FrequencyChanged {
+		changes = append(changes, fmt.Sprintf("played %.0f%% -> %.0f%%", d.OldShare*100, d.NewShare*100))
+	}
+	if d.ScoreChanged {
+		changes = append(changes, fmt.Sprintf("score %.0f%% -> %.0f%%", d.Old.Score()*100, d.New.Score()*100))
+	}
+	return fmt.Sprintf("~ %v (%v)", d.Move, strings.Join(changes, ", "))
+}
+
+// String implements fmt.Stringer interface
+func (d *GraphDiff) String() string {
+	lines := make([]string, 0)
+	for _, tree := range []struct {
+		name  string
+		moves []*MoveDiff
+	}{{"White", d.White}, {"Black", d.Black}} {
+		if len(tree.moves) == 0 {
+			continue
+		}
+		buffer := new(bytes.Buffer)
+		printDiffs(buffer, "", tree.moves)
+		lines = append(lines, fmt.Sprintf("%v positions:\n%v", tree.name, buffer.String()))
+	}
+	if len(lines) == 0 {
+		return "No changes\n"
+	}
+	return strings.Join(lines, "\n")
+}
+
+// printDiffs draws the compared moves in the style of PositionNode.print
+func printDiffs(out io.Writer, prefix string, diffs []*MoveDiff) {
+	lastDiffIndex := len(diffs) - 1
+	var leftBorder, movePrefix string
+	for i, diff := range diffs {
+		if i == lastDiffIndex {
+			leftBorder = " "
+			movePrefix = "└───"
+		} else {
+			leftBorder = "│"
+			movePrefix = "├───"
+		}
+		transposition := ""
+		if diff.Transposition {
+			transposition = " (transposition)"
+		}
+		_, _ = fmt.Fprintf(out, "%v %v%v\n", prefix+movePrefix, diff, transposition)
+		printDiffs(out, prefix+leftBorder+"     ", diff.Moves)
+	}
+}
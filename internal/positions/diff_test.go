@@ -0,0 +1,94 @@
+package positions
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Hofsiedge/ChessOpeningAnalyzer/internal/fetching"
+)
+
+func TestDiff(t *testing.T) {
+	before, _ := NewPositionGraph(3)
+	after, _ := NewPositionGraph(3)
+	for _, game := range []struct {
+		graph  *PositionGraph
+		white  bool
+		moves  string
+		result fetching.Result
+	}{
+		{before, true, "e4 e5 Nf3", fetching.Loss},
+		{before, true, "e4 e5 Nf3", fetching.Loss},
+		{before, true, "e4 e5 Nf3", fetching.Loss},
+		{before, true, "e4 c5 Nf3", fetching.Win},
+		{before, true, "d4 d5 c4", fetching.Win},
+		{before, false, "e4 e5 Nf3", fetching.Draw},
+		{after, true, "e4 e5 Nf3", fetching.Win},
+		{after, true, "e4 e5 Nf3", fetching.Win},
+		{after, true, "e4 e5 Nf3", fetching.Win},
+		{after, true, "e4 c5 Nc3", fetching.Win},
+		{after, true, "e4 c5 Nc3", fetching.Win},
+		{after, true, "e4 c5 Nc3", fetching.Win},
+		{after, false, "e4 e5 Nf3", fetching.Draw},
+	} {
+		if err := game.graph.AddGame(fetching.UserGame{
+			White:  game.white,
+			Moves:  strings.Split(game.moves, " "),
+			Result: game.result,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	diff := Diff(before, after, DefaultDiffOptions)
+	if len(diff.Black) != 0 {
+		t.Errorf("expected the black repertoire not to change, got %v", diff.Black)
+	}
+	expected := `White positions:
+├─── ~ e4 (score 25% -> 100%)
+│     ├─── ~ e5 (played 75% -> 50%, score 0% -> 100%)
+│     │     └─── ~ Nf3 (score 0% -> 100%)
+│     └─── ~ c5 (played 25% -> 50%)
+│           ├─── + Nc3 [3 games, +3 =0 -0]
+│           └─── - Nf3 [1 game, +1 =0 -0]
+└─── - d4 [1 game, +1 =0 -0]
+      └─── - d5 [1 game, +1 =0 -0]
+            └─── - c4 [1 game, +1 =0 -0]
+`
+	if got := diff.String(); got != expected {
+		t.Errorf("expected:\n%v\ngot:\n%v", expected, got)
+	}
+
+	if got := Diff(after, after, DefaultDiffOptions).String(); got != "No changes\n" {
+		t.Errorf("expected no changes comparing a graph with itself, got:\n%v", got)
+	}
+}
+
+func TestDiff_Transpositions(t *testing.T) {
+	before, _ := NewPositionGraph(5)
+	after, _ := NewPositionGraph(5)
+	for _, game := range []struct {
+		graph *PositionGraph
+		moves string
+	}{
+		{before, "e4 e5 Nf3 Nc6"},
+		{after, "e4 e5 Nf3 Nc6 Bb5"},
+		{after, "Nf3 Nc6 e4 e5"},
+	} {
+		if err := game.graph.AddGame(fetching.UserGame{White: true, Moves: strings.Split(game.moves, " ")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected := `White positions:
+├─── ~ e4 (played 100% -> 50%)
+│     └─── e5
+│           └─── Nf3
+│                 └─── Nc6
+│                       └─── + Bb5 [1 game, +0 =0 -0]
+└─── + Nf3 [1 game, +0 =0 -0]
+      └─── + Nc6 [1 game, +0 =0 -0]
+            └─── + e4 [1 game, +0 =0 -0]
+                  └─── + e5 [1 game, +0 =0 -0] (transposition)
+`
+	if got := Diff(before, after, DefaultDiffOptions).String(); got != expected {
+		t.Errorf("expected:\n%v\ngot:\n%v", expected, got)
+	}
+}
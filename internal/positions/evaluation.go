@@ -0,0 +1,139 @@
+package positions
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Perspective tells whose point of view an evaluation is given from
+type Perspective uint8
+
+const (
+	// WhitePerspective evaluations are positive when white is better
+	WhitePerspective Perspective = iota
+	// SideToMovePerspective evaluations are positive when the side to move is better, as reported by UCI engines
+	SideToMovePerspective
+)
+
+// MateValue is the centipawn value of a checkmate on the board, see Evaluation.Value
+const MateValue = 100000
+
+// WDL is a win/draw/loss estimate in per mille
+type WDL struct {
+	Win  int
+	Draw int
+	Loss int
+}
+
+// Evaluation is an engine's assessment of a position
+type Evaluation struct {
+	Perspective Perspective
+	// WhiteToMove is required to interpret a checkmate on the board and to change the perspective
+	WhiteToMove bool
+	// Centipawns is meaningful only if Mate is false
+	Centipawns int
+	Mate       bool
+	// MateIn is the number of moves to mate, negative if the perspective side is getting mated.
+	// Zero means that the side to move is already checkmated
+	MateIn int
+	Depth  int
+	Nodes  int64
+	// WDL is nil unless the engine reported it (see UCI_ShowWDL)
+	WDL *WDL
+	// BestMove and PV are in SAN
+	BestMove string
+	PV       []string
+	// Source is the engine name or the platform (e.g. "lichess") the evaluation comes from
+	Source string
+}
+
+// Value converts the evaluation into centipawns. Forced mates are worth MateValue minus the number of moves to mate
+func (e Evaluation) Value() int {
+	if !e.Mate {
+		return e.Centipawns
+	}
+	switch {
+	case e.MateIn > 0:
+		return MateValue - e.MateIn
+	case e.MateIn < 0:
+		return -MateValue - e.MateIn
+	}
+	// a checkmate on the board is a loss for the side to move
+	if (e.Perspective == SideToMovePerspective) || e.WhiteToMove {
+		return -MateValue
+	}
+	return MateValue
+}
+
+// WithPerspective returns the same evaluation given from the point of view of p
+func (e Evaluation) WithPerspective(p Perspective) Evaluation {
+	if e.Perspective == p {
+		return e
+	}
+	result := e
+	result.Perspective = p
+	if e.WhiteToMove {
+		return result
+	}
+	// black to move: side to move and white perspectives are opposite
+	result.Centipawns = -e.Centipawns
+	result.MateIn = -e.MateIn
+	if e.WDL != nil {
+		result.WDL = &WDL{Win: e.WDL.Loss, Draw: e.WDL.Draw, Loss: e.WDL.Win}
+	}
+	return result
+}
+
+// Score formats the evaluation without the search details: `+0.45`, `#-3`
+func (e Evaluation) Score() string {
+	if e.Mate {
+		return fmt.Sprintf("#%d", e.MateIn)
+	}
+	return fmt.Sprintf("%+.2f", float64(e.Centipawns)/100)
+}
+
+// String implements fmt.Stringer interface: `+0.45 (d24)`, `#-3 (d30)`
+func (e Evaluation) String() string {
+	parts := []string{e.Score()}
+	if e.Depth > 0 {
+		parts = append(parts, fmt.Sprintf("(d%d)", e.Depth))
+	}
+	if e.WDL != nil {
+		parts = append(parts, fmt.Sprintf("[%d/%d/%d]", e.WDL.Win, e.WDL.Draw, e.WDL.Loss))
+	}
+	return strings.Join(parts, " ")
+}
+
+// BetterAlternatives returns the engine's moves in the position that are evaluated higher than the played move
+// from the point of view of the side that made it. The played move is valued by its own line among the
+// alternatives or, failing that, by the evaluation of the position it leads to.
+// If the played move has not been evaluated at all, every other alternative is returned
+func (n *PositionNode) BetterAlternatives(move *Move) []Evaluation {
+	sign := 1
+	if !n.Position.FEN.WhiteToMove() {
+		sign = -1
+	}
+	value := func(e Evaluation) int {
+		return sign * e.WithPerspective(WhitePerspective).Value()
+	}
+	played, evaluated := 0, false
+	for _, alternative := range n.Alternatives {
+		if alternative.BestMove == move.Move {
+			played, evaluated = value(alternative), true
+			break
+		}
+	}
+	if !evaluated && move.To.Position.Evaluated {
+		played, evaluated = value(move.To.Position.Evaluation), true
+	}
+	better := make([]Evaluation, 0)
+	for _, alternative := range n.Alternatives {
+		if alternative.BestMove == move.Move {
+			continue
+		}
+		if !evaluated || value(alternative) > played {
+			better = append(better, alternative)
+		}
+	}
+	return better
+}
@@ -0,0 +1,123 @@
+package positions
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEvaluation_Value(t *testing.T) {
+	tests := []struct {
+		name       string
+		evaluation Evaluation
+		want       int
+	}{
+		{"centipawns", Evaluation{Centipawns: -45}, -45},
+		{"mating", Evaluation{Mate: true, MateIn: 3}, MateValue - 3},
+		{"getting mated", Evaluation{Mate: true, MateIn: -3}, -MateValue + 3},
+		{"white is checkmated", Evaluation{Mate: true, WhiteToMove: true}, -MateValue},
+		{"black is checkmated", Evaluation{Mate: true, WhiteToMove: false}, MateValue},
+		{"side to move is checkmated", Evaluation{Perspective: SideToMovePerspective, Mate: true}, -MateValue},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.evaluation.Value(); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+	if (Evaluation{Mate: true, MateIn: 2}).Value() <= (Evaluation{Mate: true, MateIn: 5}).Value() {
+		t.Error("expected a shorter mate to be worth more")
+	}
+}
+
+func TestEvaluation_WithPerspective(t *testing.T) {
+	blackToMove := Evaluation{
+		Perspective: SideToMovePerspective,
+		Centipawns:  -20,
+		MateIn:      2,
+		WDL:         &WDL{Win: 10, Draw: 500, Loss: 490},
+	}
+	expected := Evaluation{
+		Perspective: WhitePerspective,
+		Centipawns:  20,
+		MateIn:      -2,
+		WDL:         &WDL{Win: 490, Draw: 500, Loss: 10},
+	}
+	if got := blackToMove.WithPerspective(WhitePerspective); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+	if got := expected.WithPerspective(SideToMovePerspective); !reflect.DeepEqual(got, blackToMove) {
+		t.Errorf("expected %+v, got %+v", blackToMove, got)
+	}
+	whiteToMove := Evaluation{Perspective: SideToMovePerspective, WhiteToMove: true, Centipawns: 15}
+	if got := whiteToMove.WithPerspective(WhitePerspective); got.Centipawns != 15 || got.Perspective != WhitePerspective {
+		t.Errorf("expected the same score from white's point of view, got %+v", got)
+	}
+}
+
+func TestEvaluation_String(t *testing.T) {
+	tests := []struct {
+		evaluation Evaluation
+		want       string
+	}{
+		{Evaluation{Centipawns: 45, Depth: 24}, "+0.45 (d24)"},
+		{Evaluation{Centipawns: -120}, "-1.20"},
+		{Evaluation{Mate: true, MateIn: -3}, "#-3"},
+		{Evaluation{Mate: true, MateIn: 5, Depth: 30, WDL: &WDL{1000, 0, 0}}, "#5 (d30) [1000/0/0]"},
+	}
+	for _, tt := range tests {
+		if got := tt.evaluation.String(); got != tt.want {
+			t.Errorf("expected %q, got %q", tt.want, got)
+		}
+	}
+}
+
+func TestPositionNode_BetterAlternatives(t *testing.T) {
+	// 1. e4 e5 - white to move
+	node := &PositionNode{
+		Position: &Position{FEN: "rnbqkbnr/pppp1ppp/8/4p3/4P3/8/PPPP1PPP/RNBQKBNR w KQkq"},
+		Alternatives: []Evaluation{
+			{Centipawns: 40, BestMove: "Nf3"},
+			{Centipawns: 30, BestMove: "Nc3"},
+			{Centipawns: 10, BestMove: "Bc4"},
+		},
+	}
+	played := func(move string, evaluation *Evaluation) *Move {
+		to := &PositionNode{Position: &Position{}}
+		if evaluation != nil {
+			to.Position.Evaluated = true
+			to.Position.Evaluation = *evaluation
+		}
+		return &Move{To: to, Move: move}
+	}
+	moves := func(evaluations []Evaluation) []string {
+		result := make([]string, len(evaluations))
+		for i, e := range evaluations {
+			result[i] = e.BestMove
+		}
+		return result
+	}
+	tests := []struct {
+		name string
+		move *Move
+		want []string
+	}{
+		{"played line among alternatives", played("Bc4", nil), []string{"Nf3", "Nc3"}},
+		{"played the best move", played("Nf3", &Evaluation{Centipawns: -100}), []string{}},
+		{"evaluated position", played("Qh5", &Evaluation{Centipawns: 35}), []string{"Nf3"}},
+		{"not evaluated", played("Qh5", nil), []string{"Nf3", "Nc3", "Bc4"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := moves(node.BetterAlternatives(tt.move)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	// black to move - lower evaluations are better for black
+	node.Position.FEN = "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq"
+	if got := moves(node.BetterAlternatives(played("Nc3", nil))); !reflect.DeepEqual(got, []string{"Bc4"}) {
+		t.Errorf("expected [Bc4], got %v", got)
+	}
+}
@@ -1,6 +1,7 @@
 package positions
 
 import (
+	"errors"
 	"fmt"
 	"github.com/Hofsiedge/ChessOpeningAnalyzer/internal/fetching"
 	"github.com/notnil/chess"
@@ -11,27 +12,81 @@ import (
 type FEN string
 
 type Position struct {
-	FEN       FEN
-	Score     float32
-	Evaluated bool
+	// FEN is used for display and for the engine, positions are identified by Key
+	FEN        FEN
+	Key        Key
+	Evaluated  bool
+	Evaluation Evaluation
 }
 
 type PositionNode struct {
-	Position   *Position
-	LastPlayed time.Time
-	Moves      []*Move
+	Position *Position
+	// FirstPlayed and LastPlayed are the end times of the first and the last games the position was reached in
+	FirstPlayed time.Time
+	LastPlayed  time.Time
+	// MonthlyPlayed is the number of games the position was reached in per month, keyed like "2021-07"
+	MonthlyPlayed map[string]int
+	Moves         []*Move
+	// Alternatives are the engine's best lines in the position ordered by preference.
+	// Evaluation.BestMove of each of them is the move the line starts with
+	Alternatives []Evaluation
 }
 
 type Move struct {
 	To   *PositionNode
 	Move string
+	// Played is the number of games the move was played in
+	Played int
+	// Wins, Draws and Losses split Played by the results of the games from the user's point of view.
+	// Games with an unknown result are not counted
+	Wins   int
+	Draws  int
+	Losses int
+	// FirstPlayed and LastPlayed are the end times of the first and the last games the move was played in.
+	// They are zero in graphs created before the moves were dated
+	FirstPlayed time.Time
+	LastPlayed  time.Time
+	// MonthlyPlayed is the number of games the move was played in per month like PositionNode.MonthlyPlayed.
+	// It is empty in graphs before version 6
+	MonthlyPlayed map[string]int
 }
 
+// addResult counts a game played through the move
+func (m *Move) addResult(result fetching.Result) {
+	switch result {
+	case fetching.Win:
+		m.Wins++
+	case fetching.Draw:
+		m.Draws++
+	case fetching.Loss:
+		m.Losses++
+	}
+}
+
+// Score returns the share of points the user scored in the games with a known result, 0.5 if there are none
+func (m *Move) Score() float64 {
+	games := m.Wins + m.Draws + m.Losses
+	if games == 0 {
+		return 0.5
+	}
+	return (float64(m.Wins) + float64(m.Draws)/2) / float64(games)
+}
+
+// GraphVersion is the current format version of PositionGraph, see LoadGraph
+const GraphVersion = 7
+
 type PositionGraph struct {
+	// Version is the format version the graph was created with
+	Version        int
 	Depth          int
 	WhitePositions *PositionNode
 	BlackPositions *PositionNode
-	PositionMap    map[FEN]*PositionNode
+	// WhitePositionIndex and BlackPositionIndex index the nodes of the corresponding trees by Key, except for the roots.
+	// The trees never share nodes, so the white and the black repertoires do not mix.
+	// A game never repeats a position, but games that transpose into each other's earlier positions can form
+	// cycles, so every traversal of the graph has to track the visited nodes or the current path
+	WhitePositionIndex map[Key]*PositionNode
+	BlackPositionIndex map[Key]*PositionNode
 }
 
 func NewPositionGraph(depth int) (*PositionGraph, error) {
@@ -39,84 +94,330 @@ func NewPositionGraph(depth int) (*PositionGraph, error) {
 		return nil, fmt.Errorf("expected depth > 1, got: %v", depth)
 	}
 	graph := PositionGraph{
-		Depth:       depth,
-		PositionMap: make(map[FEN]*PositionNode, 30),
+		Version:            GraphVersion,
+		Depth:              depth,
+		WhitePositionIndex: make(map[Key]*PositionNode, 30),
+		BlackPositionIndex: make(map[Key]*PositionNode, 30),
 	}
 	for _, positions := range []**PositionNode{&graph.WhitePositions, &graph.BlackPositions} {
 		*positions = &PositionNode{
 			Position: &Position{
-				FEN:       FEN(chess.StartingPosition().String()),
-				Score:     0,
-				Evaluated: true,
+				FEN: FEN(chess.StartingPosition().String()),
+				Key: NewKey(chess.StartingPosition()),
 			},
-			LastPlayed: time.Time{},
 		}
 	}
 	return &graph, nil
 }
 
-// truncateFEN removes move counters & last captures since they are not important for opening analysis.
-// It allows to build a more representative cache in PositionGraph.AddGame
-func truncateFEN(fen string) FEN {
-	words := strings.Split(fen, " ")
-	return FEN(strings.Join(words[:len(words)-3], " "))
+// TruncateFEN removes move counters since they are not important for opening analysis. The en passant square
+// is only kept if an en passant capture is legal, so positions that differ by an unusable square are the same.
+// It accepts complete and truncated FENs
+func TruncateFEN(fen string) FEN {
+	words := strings.Fields(fen)
+	if len(words) > 4 {
+		words = words[:4]
+	}
+	if len(words) == 4 && words[3] != "-" {
+		if parsed, err := chess.FEN(strings.Join(words, " ") + " 0 1"); err == nil {
+			return positionFEN(chess.NewGame(parsed).Position())
+		}
+	}
+	if len(words) > 3 {
+		words = words[:3]
+	}
+	return FEN(strings.Join(words, " "))
+}
+
+// positionFEN returns the FEN of the position in the form stored in Position.FEN, see TruncateFEN
+func positionFEN(pos *chess.Position) FEN {
+	words := strings.Fields(pos.String())
+	if enPassantCapturable(pos) {
+		return FEN(strings.Join(words[:4], " "))
+	}
+	return FEN(strings.Join(words[:3], " "))
+}
+
+// Full restores a complete FEN that can be passed to a chess engine.
+// Truncated FENs get reset move counters and an empty en passant square if they have none
+func (f FEN) Full() string {
+	switch len(strings.Split(string(f), " ")) {
+	case 3:
+		return string(f) + " - 0 1"
+	case 4:
+		return string(f) + " 0 1"
+	}
+	return string(f)
+}
+
+// WhiteToMove reports whether it is white's turn in the position
+func (f FEN) WhiteToMove() bool {
+	words := strings.Split(string(f), " ")
+	return len(words) < 2 || words[1] != "b"
 }
 
 // AddGame adds the first moves of the game to the position graph
 func (g *PositionGraph) AddGame(game fetching.UserGame) error {
-	board := chess.NewGame()
-	var currentNode *PositionNode
+	board := chess.StartingPosition()
+	currentNode, positionIndex := g.BlackPositions, g.BlackPositionIndex
 	if game.White {
-		currentNode = g.WhitePositions
-	} else {
-		currentNode = g.BlackPositions
+		currentNode, positionIndex = g.WhitePositions, g.WhitePositionIndex
 	}
-	for _, move := range game.Moves {
-		if err := board.MoveStr(move); err != nil {
+	currentNode.addPlay(game.EndTime)
+	// a game that repeats a position is added up to the repetition. A game never links back to its own positions,
+	// so the moves and the positions of a game are counted once
+	path := map[*PositionNode]bool{currentNode: true}
+	for i, move := range game.Moves {
+		var err error
+		if board, err = playMove(board, move); err != nil {
 			return err
 		}
-		var nextNode *PositionNode
-		pos := truncateFEN(board.Position().String())
-		if node, found := g.PositionMap[pos]; found {
-			nextNode = node
-		} else {
+		key := NewKey(board)
+		// FindPosition also finds the starting position, which is not indexed
+		nextNode := g.FindPosition(game.White, key)
+		if nextNode == nil {
+			// FENs are only built for new positions
 			nextNode = &PositionNode{
 				Position: &Position{
-					FEN: pos,
+					FEN: positionFEN(board),
+					Key: key,
 				},
-				LastPlayed: game.EndTime,
 			}
-			g.PositionMap[pos] = nextNode
-			currentNode.Moves = append(currentNode.Moves, &Move{nextNode, move})
+			positionIndex[key] = nextNode
+		}
+		if path[nextNode] {
+			break
+		}
+		path[nextNode] = true
+		// the position may be known from a different move order (a transposition), but the edge may still be new
+		edge := currentNode.findMove(move)
+		if edge == nil {
+			edge = &Move{To: nextNode, Move: move}
+			currentNode.Moves = append(currentNode.Moves, edge)
+		}
+		edge.Played++
+		edge.addResult(game.Result)
+		edge.addPlay(game.EndTime)
+		nextNode.addPlay(game.EndTime)
+		if i < len(game.Evaluations) && game.Evaluations[i] != nil {
+			nextNode.Position.importEvaluation(game.Evaluations[i])
 		}
 		currentNode = nextNode
 	}
 	return nil
 }
 
-// TODO: accept a context or a `done` channel
+// ErrInvalidGame is passed to the error handler of AddStream for the games that could not be added
+var ErrInvalidGame = errors.New("invalid game")
+
+// AddStream adds the games of the stream as they arrive, so the whole list of games is never kept in memory.
+// The errors of the stream and the games that could not be added (wrapping ErrInvalidGame) are passed to onError.
+// AddStream stops at the first error returned by onError (or at the first error at all if onError is nil) and returns it,
+// so the caller should cancel the stream then.
+// Otherwise it reads the stream until it is closed. It returns the number of added games
+func (g *PositionGraph) AddStream(stream <-chan fetching.StreamedGame, onError func(error) error) (int, error) {
+	return g.addStream(nil, stream, onError)
+}
 
-// GetVariations returns all the move sequences from the first move to the last one
-// that are present in the position graph to the output channel
-func (g PositionGraph) GetVariations() <-chan []*Move {
-	out := make(chan []*Move)
-	go func() {
-		for _, startingNode := range []*PositionNode{g.WhitePositions, g.BlackPositions} {
-			for _, move := range startingNode.Moves {
-				traverseMoves(move, make([]*Move, 0, 1), out)
+// addStream is AddStream that also stops without an error when done is closed. A nil done never stops it
+func (g *PositionGraph) addStream(done <-chan struct{}, stream <-chan fetching.StreamedGame, onError func(error) error) (int, error) {
+	added := 0
+	for {
+		var (
+			item fetching.StreamedGame
+			ok   bool
+		)
+		select {
+		case item, ok = <-stream:
+		case <-done:
+		}
+		if !ok {
+			return added, nil
+		}
+		err := item.Err
+		if err == nil {
+			if err = g.AddGame(*item.Game); err == nil {
+				added++
+				continue
 			}
+			err = fmt.Errorf("%w %v: %w", ErrInvalidGame, item.Game.URL, err)
+		}
+		if onError != nil {
+			err = onError(err)
+		}
+		if err != nil {
+			return added, err
+		}
+	}
+}
+
+// importEvaluation attaches a platform's evaluation to the position unless it has a deeper one
+func (p *Position) importEvaluation(e *fetching.Evaluation) {
+	if p.Evaluated && p.Evaluation.Depth >= e.Depth {
+		return
+	}
+	p.Evaluated = true
+	p.Evaluation = Evaluation{
+		Perspective: WhitePerspective,
+		WhiteToMove: p.FEN.WhiteToMove(),
+		Centipawns:  e.Centipawns,
+		Mate:        e.Mate,
+		MateIn:      e.MateIn,
+		Depth:       e.Depth,
+		Source:      e.Source,
+	}
+}
+
+// monthKey formats the month of t as a key of PositionNode.MonthlyPlayed
+func monthKey(t time.Time) string {
+	return t.Format("2006-01")
+}
+
+// extendDates extends the range [first, last] to include t. Zero values of t are ignored
+func extendDates(first, last *time.Time, t time.Time) {
+	if t.IsZero() {
+		return
+	}
+	if first.IsZero() || t.Before(*first) {
+		*first = t
+	}
+	if t.After(*last) {
+		*last = t
+	}
+}
+
+// addPlay records a game that reached the position. Games without a date are not recorded
+func (n *PositionNode) addPlay(endTime time.Time) {
+	addPlay(&n.FirstPlayed, &n.LastPlayed, &n.MonthlyPlayed, endTime)
+}
+
+// addPlay records a game the move was played in. Games without a date are not recorded
+func (m *Move) addPlay(endTime time.Time) {
+	addPlay(&m.FirstPlayed, &m.LastPlayed, &m.MonthlyPlayed, endTime)
+}
+
+// addPlay extends the dates and the monthly history to include a game that ended at endTime
+func addPlay(first, last *time.Time, monthly *map[string]int, endTime time.Time) {
+	if endTime.IsZero() {
+		return
+	}
+	extendDates(first, last, endTime)
+	if *monthly == nil {
+		*monthly = make(map[string]int)
+	}
+	(*monthly)[monthKey(endTime)]++
+}
+
+// PlayedBetween reports whether the position was reached in a game that ended within [since, until].
+// Zero values leave the corresponding side of the window open. Games are only counted per month,
+// so a game of the same month as since or until but outside of the window may be taken into account
+func (n *PositionNode) PlayedBetween(since, until time.Time) bool {
+	return playedBetween(n.FirstPlayed, n.LastPlayed, n.MonthlyPlayed, since, until)
+}
+
+// PlayedBetween reports whether the move was played in a game that ended within [since, until]
+// in the same way as PositionNode.PlayedBetween. A position may be reached by several moves,
+// so the dates of the move are used rather than the dates of the position it leads to.
+// Moves of older graphs fall back to the history of the position
+func (m *Move) PlayedBetween(since, until time.Time) bool {
+	if m.LastPlayed.IsZero() {
+		// graphs created before the moves were dated
+		return m.To.PlayedBetween(since, until)
+	}
+	if !playedBetween(m.FirstPlayed, m.LastPlayed, m.MonthlyPlayed, since, until) {
+		return false
+	}
+	// the moves had no monthly history before version 6
+	return len(m.MonthlyPlayed) > 0 || m.To.PlayedBetween(since, until)
+}
+
+// dates returns the dates of the first and the last games the move was played in.
+// Moves of older graphs fall back to the dates of the position like in PlayedBetween
+func (m *Move) dates() (time.Time, time.Time) {
+	if m.LastPlayed.IsZero() {
+		return m.To.FirstPlayed, m.To.LastPlayed
+	}
+	return m.FirstPlayed, m.LastPlayed
+}
+
+// playedBetween checks the dates and the monthly history of a position or a move against [since, until].
+// Without a monthly history only the dates are checked
+func playedBetween(first, last time.Time, monthly map[string]int, since, until time.Time) bool {
+	if last.IsZero() {
+		return false
+	}
+	if (!since.IsZero() && last.Before(since)) || (!until.IsZero() && first.After(until)) {
+		return false
+	}
+	if len(monthly) == 0 {
+		return true
+	}
+	for month, played := range monthly {
+		if played == 0 {
+			continue
+		}
+		if (since.IsZero() || month >= monthKey(since)) && (until.IsZero() || month <= monthKey(until)) {
+			return true
+		}
+	}
+	return false
+}
+
+// PositionMap returns the index of the white or the black repertoire
+func (g *PositionGraph) PositionMap(white bool) map[Key]*PositionNode {
+	if white {
+		return g.WhitePositionIndex
+	}
+	return g.BlackPositionIndex
+}
+
+// Nodes returns every node of the graph except for the roots: the white repertoire first, then the black one.
+// A position played with both colours has a node in each of them
+func (g *PositionGraph) Nodes() []*PositionNode {
+	nodes := make([]*PositionNode, 0, len(g.WhitePositionIndex)+len(g.BlackPositionIndex))
+	for _, positionIndex := range []map[Key]*PositionNode{g.WhitePositionIndex, g.BlackPositionIndex} {
+		for _, node := range positionIndex {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// FindLine returns the node of the white or the black repertoire reached by the moves in SAN
+// played from the starting position or nil if the line is not in the graph
+func (g *PositionGraph) FindLine(white bool, moves []string) *PositionNode {
+	node := g.BlackPositions
+	if white {
+		node = g.WhitePositions
+	}
+	for _, move := range moves {
+		edge := node.findMove(move)
+		if edge == nil {
+			return nil
 		}
-		close(out)
-	}()
-	return out
+		node = edge.To
+	}
+	return node
 }
 
-func traverseMoves(move *Move, previousMoves []*Move, out chan<- []*Move) {
-	moves := append(previousMoves, move)
-	if len(move.To.Moves) == 0 {
-		out <- moves
+// FindPosition returns the node of the white or the black repertoire with the position or nil if it is not in the graph
+func (g *PositionGraph) FindPosition(white bool, key Key) *PositionNode {
+	root := g.BlackPositions
+	if white {
+		root = g.WhitePositions
 	}
-	for _, nextMove := range move.To.Moves {
-		traverseMoves(nextMove, moves, out)
+	if root.Position.Key == key {
+		return root
 	}
+	return g.PositionMap(white)[key]
+}
+
+// findMove returns the edge for the move in SAN or nil if the move has never been played from the node
+func (n *PositionNode) findMove(move string) *Move {
+	for _, m := range n.Moves {
+		if m.Move == move {
+			return m
+		}
+	}
+	return nil
 }
@@ -1,6 +1,10 @@
 package positions
 
 import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -58,9 +62,12 @@ func TestAddGame(t *testing.T) {
 		{"e4", "e5", "Nf3", "Nc6"},
 		{"e4", "c5", "c3", "Nc6"},
 	}
-	variations := graph.GetVariations()
+	variations := graph.Variations(context.Background())
 	for _, expected := range expectedVariations {
-		v := <-variations
+		if !variations.Next() {
+			t.Fatalf("expected variation %v, got none", expected)
+		}
+		v := variations.Variation()
 		if lv, le := len(v), len(expected); lv != le {
 			t.Errorf("wrong number of moves - expected %v, got %v", le, lv)
 		}
@@ -71,3 +78,427 @@ func TestAddGame(t *testing.T) {
 		}
 	}
 }
+
+func TestAddGame_Evaluations(t *testing.T) {
+	graph, _ := NewPositionGraph(3)
+	games := []fetching.UserGame{{
+		White:   true,
+		EndTime: time.Date(2021, 1, 1, 1, 0, 0, 0, time.UTC),
+		Moves:   []string{"e4", "e5", "Qh5"},
+		Evaluations: []*fetching.Evaluation{
+			{Source: "lichess", Centipawns: 36},
+			nil,
+			{Source: "lichess", Mate: true, MateIn: -5, Depth: 20},
+		},
+	}, {
+		White:       true,
+		EndTime:     time.Date(2021, 1, 1, 1, 0, 0, 0, time.UTC),
+		Moves:       []string{"e4", "e5", "Qh5"},
+		Evaluations: []*fetching.Evaluation{{Source: "lichess", Centipawns: 20, Depth: 18}, nil, {Source: "lichess", Depth: 10}},
+	}}
+	for _, game := range games {
+		if err := graph.AddGame(game); err != nil {
+			t.Fatal(err)
+		}
+	}
+	e4 := graph.WhitePositions.Moves[0].To
+	e5 := e4.Moves[0].To
+	qh5 := e5.Moves[0].To
+	if e := e4.Position.Evaluation; !e4.Position.Evaluated || e.Centipawns != 20 || e.Depth != 18 || e.Source != "lichess" {
+		t.Errorf("expected the deeper evaluation to replace the first one, got %+v", e)
+	}
+	if e5.Position.Evaluated {
+		t.Errorf("expected a position without an evaluation, got %+v", e5.Position.Evaluation)
+	}
+	if e := qh5.Position.Evaluation; e.String() != "#-5 (d20)" || e.WhiteToMove || e.Value() != -MateValue+5 {
+		t.Errorf("expected the deeper evaluation to be kept, got %+v", e)
+	}
+}
+
+func TestAddGame_Transpositions(t *testing.T) {
+	graph, _ := NewPositionGraph(6)
+	for _, moves := range []string{
+		"e4 e5 Nf3 Nc6",
+		"Nf3 Nc6 e4 e5",
+		"Nf3 Nc6 e4 e5",
+		// returns to the starting position
+		"Nf3 Nf6 Ng1 Ng8 e4",
+	} {
+		if err := graph.AddGame(fetching.UserGame{
+			White:   true,
+			EndTime: time.Date(2021, 1, 1, 1, 0, 0, 0, time.UTC),
+			Moves:   strings.Split(moves, " "),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	nf3Nc6 := graph.WhitePositions.Moves[1].To.Moves[0].To
+	e4e5 := graph.WhitePositions.Moves[0].To.Moves[0].To
+	transposed := e4e5.Moves[0].To.Moves[0].To
+	if len(nf3Nc6.Moves) != 1 || nf3Nc6.Moves[0].To.Moves[0].To != transposed || nf3Nc6.Moves[0].To.Moves[0].Played != 2 {
+		t.Errorf("expected the second move order to lead to the same node twice, got %v", nf3Nc6.Moves)
+	}
+
+	expectedVariations := []string{
+		"e4 e5 Nf3 Nc6",
+		"Nf3 Nc6 e4 e5",
+		"Nf3 Nf6 Ng1",
+	}
+	variations := make([]string, 0)
+	for it := graph.Variations(context.Background()); it.Next(); {
+		variation := it.Variation()
+		moves := make([]string, len(variation))
+		for i, move := range variation {
+			moves[i] = move.Move
+		}
+		variations = append(variations, strings.Join(moves, " "))
+	}
+	if !reflect.DeepEqual(variations, expectedVariations) {
+		t.Errorf("expected variations %v, got %v", expectedVariations, variations)
+	}
+
+	expected := `├─── e4
+│     └─── e5
+│           └─── Nf3
+│                 └─── Nc6
+└─── Nf3
+      ├─── Nc6
+      │     └─── e4
+      │           └─── e5 (transposition)
+      └─── Nf6
+            └─── Ng1
+`
+	if got := graph.WhitePositions.String(); got != expected {
+		t.Errorf("expected:\n%v\ngot:\n%v", expected, got)
+	}
+	if _, found := graph.WhitePositionIndex[graph.WhitePositions.Position.Key]; found {
+		t.Error("expected the starting position to have a single node")
+	}
+
+	// the game stops before 4. ... Ng8 repeats the position after 2. ... e5, so there is no cycle to save
+	graph, _ = NewPositionGraph(8)
+	if err := graph.AddGame(fetching.UserGame{
+		White: true,
+		Moves: strings.Split("e4 e5 Nf3 Nf6 Ng1 Ng8 Nf3 Nf6", " "),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	ng1 := graph.WhitePositions.Moves[0].To.Moves[0].To.Moves[0].To.Moves[0].To.Moves[0]
+	if ng1.Move != "Ng1" || len(ng1.To.Moves) != 0 || ng1.Played != 1 {
+		t.Errorf("expected the game to stop after 4. Ng1, got %v", ng1.To.Moves)
+	}
+	path := filepath.Join(t.TempDir(), "graph.bin")
+	if err := DumpGraph(graph, path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadGraph(path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAddGame_Cycle(t *testing.T) {
+	graph, _ := NewPositionGraph(6)
+	for _, moves := range []string{"Nc3 Nc6 Nf3 Nf6 Nb1 Nb8", "Nf3 Nf6 Nc3 Nc6 Ng1 Ng8"} {
+		if err := graph.AddGame(fetching.UserGame{White: true, Moves: strings.Split(moves, " ")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 1. Nc3 Nc6 2. Nf3 Nf6 3. Ng1 Ng8 leads back to the position after 1. Nc3 Nc6
+	nc6 := graph.FindLine(true, []string{"Nc3", "Nc6"})
+	if back := graph.FindLine(true, []string{"Nc3", "Nc6", "Nf3", "Nf6", "Ng1", "Ng8"}); back != nc6 {
+		t.Fatalf("expected the games to form a cycle, got %v", back)
+	}
+
+	// every traversal stops at the cycle
+	if printed := graph.String(); strings.Count(printed, "(transposition)") != 3 {
+		t.Errorf("expected the cycle to be printed as transpositions, got:\n%v", printed)
+	}
+	variations := 0
+	for it := graph.Variations(context.Background()); it.Next(); {
+		variations++
+	}
+	if variations != 4 {
+		t.Errorf("expected 4 variations, got %v", variations)
+	}
+	if paths := graph.Search(true, nc6.Position.Key); len(paths) != 2 {
+		t.Errorf("expected 2 move orders reaching 1. Nc3 Nc6, got %v", paths)
+	}
+	path := filepath.Join(t.TempDir(), "graph.bin")
+	if err := DumpGraph(graph, path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadGraph(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.FindLine(true, []string{"Nc3", "Nc6", "Nf3", "Nf6", "Ng1", "Ng8"}) != loaded.FindLine(true, []string{"Nc3", "Nc6"}) {
+		t.Error("expected the cycle to be kept by a loaded graph")
+	}
+	merged, _, err := Merge(graph, loaded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := Diff(graph, merged, DiffOptions{Frequency: 1, Score: 1}); len(diff.White) != 0 {
+		t.Errorf("expected no changes between a graph and its double, got:\n%v", diff)
+	}
+	if _, err = merged.Prune(PruneOptions{MinPlayed: 3}); err != nil || len(merged.WhitePositionIndex) != 0 {
+		t.Errorf("expected every position to be removed, got %v (%v)", len(merged.WhitePositionIndex), err)
+	}
+}
+
+func TestAddGame_Results(t *testing.T) {
+	graph, _ := NewPositionGraph(2)
+	for _, game := range []fetching.UserGame{
+		{White: true, Moves: []string{"e4", "e5"}, Result: fetching.Win},
+		{White: true, Moves: []string{"e4", "c5"}, Result: fetching.Loss},
+		{White: true, Moves: []string{"e4", "c5"}, Result: fetching.Draw},
+		{White: true, Moves: []string{"e4", "e5"}},
+	} {
+		if err := graph.AddGame(game); err != nil {
+			t.Fatal(err)
+		}
+	}
+	e4 := graph.WhitePositions.Moves[0]
+	if e4.Played != 4 || e4.Wins != 1 || e4.Draws != 1 || e4.Losses != 1 || e4.Score() != 0.5 {
+		t.Errorf("unexpected statistics of e4: %+v", e4)
+	}
+	if c5 := e4.To.Moves[1]; c5.Played != 2 || c5.Score() != 0.25 {
+		t.Errorf("unexpected statistics of c5: %+v", c5)
+	}
+	if e5 := e4.To.Moves[0]; e5.Played != 2 || e5.Score() != 1 {
+		t.Errorf("unexpected statistics of e5: %+v", e5)
+	}
+
+	// a game passing a position twice counts once
+	graph, _ = NewPositionGraph(8)
+	if err := graph.AddGame(fetching.UserGame{
+		White:   true,
+		EndTime: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+		Result:  fetching.Win,
+		Moves:   strings.Split("Nf3 Nf6 Ng1 Ng8 Nf3 Nf6 Ng1 Ng8", " "),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	nf6 := graph.FindLine(true, []string{"Nf3"}).Moves[0]
+	if nf6.Played != 1 || nf6.Wins != 1 || nf6.To.MonthlyPlayed["2021-01"] != 1 {
+		t.Errorf("expected Nf6 to be counted once, got %+v with %v", nf6, nf6.To.MonthlyPlayed)
+	}
+	for _, node := range append(graph.Nodes(), graph.WhitePositions) {
+		for _, move := range node.Moves {
+			if move.Played != 1 {
+				t.Errorf("expected every move to be counted once, got %v played %v times", move.Move, move.Played)
+			}
+		}
+	}
+	// 2. ... Ng8 returns to the starting position, which has a single node
+	start := graph.WhitePositions
+	if _, found := graph.WhitePositionIndex[start.Position.Key]; found || len(graph.WhitePositionIndex) != 3 {
+		t.Errorf("expected the game to stop at the starting position, got %v positions", len(graph.WhitePositionIndex))
+	}
+	if start.MonthlyPlayed["2021-01"] != 1 {
+		t.Errorf("expected the starting position to be counted once, got %v", start.MonthlyPlayed)
+	}
+}
+
+func TestAddGame_Colours(t *testing.T) {
+	graph, _ := NewPositionGraph(2)
+	white := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	black := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, game := range []fetching.UserGame{
+		{White: true, EndTime: white, Moves: []string{"e4", "e5"}},
+		{White: false, EndTime: black, Moves: []string{"e4", "c5"}},
+	} {
+		if err := graph.AddGame(game); err != nil {
+			t.Fatal(err)
+		}
+	}
+	whiteE4, blackE4 := graph.WhitePositions.Moves[0].To, graph.BlackPositions.Moves[0].To
+	if whiteE4 == blackE4 || len(whiteE4.Moves) != 1 || len(blackE4.Moves) != 1 {
+		t.Errorf("expected separate nodes for each colour, got %v and %v", whiteE4.Moves, blackE4.Moves)
+	}
+	if !whiteE4.LastPlayed.Equal(white) || !blackE4.LastPlayed.Equal(black) {
+		t.Errorf("expected the dates of the games of each colour, got %v and %v", whiteE4.LastPlayed, blackE4.LastPlayed)
+	}
+	if len(graph.Nodes()) != 4 || graph.PositionMap(true)[whiteE4.Position.Key] != whiteE4 {
+		t.Errorf("unexpected indexes: %v white and %v black positions", len(graph.WhitePositionIndex), len(graph.BlackPositionIndex))
+	}
+}
+
+func TestAddGame_Dates(t *testing.T) {
+	graph, _ := NewPositionGraph(2)
+	dates := []time.Time{
+		time.Date(2021, 7, 8, 0, 0, 0, 0, time.UTC),
+		time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2021, 7, 20, 0, 0, 0, 0, time.UTC),
+	}
+	for i, moves := range []string{"e4 e5", "e4 c5", "e4 e5"} {
+		if err := graph.AddGame(fetching.UserGame{White: true, EndTime: dates[i], Moves: strings.Split(moves, " ")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	e4 := graph.WhitePositions.Moves[0].To
+	if !e4.FirstPlayed.Equal(dates[1]) || !e4.LastPlayed.Equal(dates[2]) {
+		t.Errorf("expected e4 to be played from %v to %v, got %v - %v", dates[1], dates[2], e4.FirstPlayed, e4.LastPlayed)
+	}
+	if expected := map[string]int{"2021-03": 1, "2021-07": 2}; !reflect.DeepEqual(e4.MonthlyPlayed, expected) {
+		t.Errorf("expected monthly counts %v, got %v", expected, e4.MonthlyPlayed)
+	}
+	if e5 := e4.Moves[0]; !e5.FirstPlayed.Equal(dates[0]) || !e5.LastPlayed.Equal(dates[2]) {
+		t.Errorf("expected e5 to be played from %v to %v, got %v - %v", dates[0], dates[2], e5.FirstPlayed, e5.LastPlayed)
+	}
+	if root := graph.WhitePositions; root.MonthlyPlayed["2021-07"] != 2 {
+		t.Errorf("expected the root to count every game, got %v", root.MonthlyPlayed)
+	}
+
+	c5 := e4.Moves[1].To
+	tests := []struct {
+		since, until time.Time
+		want         bool
+	}{
+		{time.Time{}, time.Time{}, true},
+		{time.Date(2021, 4, 1, 0, 0, 0, 0, time.UTC), time.Time{}, false},
+		{time.Time{}, time.Date(2021, 2, 28, 0, 0, 0, 0, time.UTC), false},
+		{time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC), time.Date(2021, 3, 31, 0, 0, 0, 0, time.UTC), true},
+	}
+	for _, tt := range tests {
+		if got := c5.PlayedBetween(tt.since, tt.until); got != tt.want {
+			t.Errorf("PlayedBetween(%v, %v) = %v, want %v", tt.since, tt.until, got, tt.want)
+		}
+	}
+	// e4 was played in March and July, but not in between
+	if e4.PlayedBetween(time.Date(2021, 4, 1, 0, 0, 0, 0, time.UTC), time.Date(2021, 6, 30, 0, 0, 0, 0, time.UTC)) {
+		t.Error("expected e4 not to be played from April to June")
+	}
+}
+
+func TestAddGame_EnPassant(t *testing.T) {
+	graph, _ := NewPositionGraph(4)
+	if err := graph.AddGame(fetching.UserGame{White: true, Moves: []string{"e4", "Nf6", "e5", "d5"}}); err != nil {
+		t.Fatal(err)
+	}
+	e4 := graph.FindLine(true, []string{"e4"})
+	if fen := e4.Position.FEN; fen != "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq" {
+		t.Errorf("expected no en passant square if black cannot capture en passant, got %v", fen)
+	}
+	// white can capture 3. exd6
+	d5 := graph.FindLine(true, []string{"e4", "Nf6", "e5", "d5"})
+	fen := d5.Position.FEN
+	if fen != "rnbqkb1r/ppp1pppp/5n2/3pP3/8/8/PPPP1PPP/RNBQKBNR w KQkq d6" {
+		t.Errorf("expected the en passant square to be kept, got %v", fen)
+	}
+	if full := fen.Full(); full != string(fen)+" 0 1" {
+		t.Errorf("expected the en passant square in the complete FEN, got %v", full)
+	}
+	if key, err := fen.Key(); err != nil || key != d5.Position.Key {
+		t.Errorf("expected the key of the FEN to match the position, got %v (%v)", key, err)
+	}
+}
+
+func TestTruncateFEN(t *testing.T) {
+	tests := map[string]FEN{
+		"rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1":    "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq",
+		"rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq":           "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq",
+		"rnbqkb1r/ppp1pppp/5n2/3pP3/8/8/PPPP1PPP/RNBQKBNR w KQkq d6 0 3": "rnbqkb1r/ppp1pppp/5n2/3pP3/8/8/PPPP1PPP/RNBQKBNR w KQkq d6",
+		"rnbqkb1r/ppp1pppp/5n2/3pP3/8/8/PPPP1PPP/RNBQKBNR w KQkq d6":     "rnbqkb1r/ppp1pppp/5n2/3pP3/8/8/PPPP1PPP/RNBQKBNR w KQkq d6",
+		"rnbqkb1r/ppp1pppp/5n2/3pP3/8/8/PPPP1PPP/RNBQKBNR w KQkq - 0 3":  "rnbqkb1r/ppp1pppp/5n2/3pP3/8/8/PPPP1PPP/RNBQKBNR w KQkq",
+		"  rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1\n":   "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq",
+	}
+	for fen, want := range tests {
+		if got := TruncateFEN(fen); got != want {
+			t.Errorf("%q: expected %v, got %v", fen, want, got)
+		}
+	}
+}
+
+func TestPositionGraph_FindLine(t *testing.T) {
+	graph, _ := NewPositionGraph(3)
+	for _, game := range []fetching.UserGame{
+		{White: true, Moves: []string{"e4", "c5", "Nf3"}},
+		{White: false, Moves: []string{"e4", "e5", "Nf3"}},
+	} {
+		if err := graph.AddGame(game); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c5 := graph.WhitePositions.Moves[0].To.Moves[0].To
+	if node := graph.FindLine(true, []string{"e4", "c5"}); node != c5 {
+		t.Errorf("expected the node after 1. e4 c5, got %v", node)
+	}
+	if node := graph.FindLine(false, []string{"e4", "c5"}); node != nil {
+		t.Errorf("expected no node for a line of the other colour, got %v", node)
+	}
+	if node := graph.FindLine(true, nil); node != graph.WhitePositions {
+		t.Errorf("expected the root for an empty line, got %v", node)
+	}
+
+	// white cannot capture en passant, so the en passant square does not matter
+	fen := FEN("rnbqkbnr/pp1ppppp/8/2p5/4P3/8/PPPP1PPP/RNBQKBNR w KQkq c6 0 2")
+	key, err := fen.Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node := graph.FindPosition(true, key); node != c5 {
+		t.Errorf("expected the node of %v, got %v", fen, node)
+	}
+	if node := graph.FindPosition(false, key); node != nil {
+		t.Errorf("expected no node of %v in the black repertoire, got %v", fen, node)
+	}
+	if node := graph.FindPosition(false, NewKey(chess.StartingPosition())); node != graph.BlackPositions {
+		t.Errorf("expected the root for the starting position, got %v", node)
+	}
+}
+
+func TestPositionGraph_AddStream(t *testing.T) {
+	errFetching := errors.New("fetching error")
+	items := []fetching.StreamedGame{
+		{Game: &fetching.UserGame{White: true, Moves: []string{"e4", "e5"}}},
+		{Game: &fetching.UserGame{White: true, Moves: []string{"e4", "e5", "Ke3"}, URL: "https://lichess.org/abcdefgh"}},
+		{Err: errFetching},
+		{Game: &fetching.UserGame{White: true, Moves: []string{"d4"}}},
+	}
+	newStream := func() <-chan fetching.StreamedGame {
+		stream := make(chan fetching.StreamedGame, len(items))
+		for _, item := range items {
+			stream <- item
+		}
+		close(stream)
+		return stream
+	}
+
+	graph, _ := NewPositionGraph(3)
+	errs := make([]error, 0)
+	added, err := graph.AddStream(newStream(), func(err error) error {
+		errs = append(errs, err)
+		return nil
+	})
+	if err != nil || added != 2 {
+		t.Fatalf("expected 2 added games without an error, got %v and \"%v\"", added, err)
+	}
+	if len(errs) != 2 || !errors.Is(errs[0], ErrInvalidGame) || !errors.Is(errs[1], errFetching) {
+		t.Errorf("expected an invalid game and a fetching error, got %v", errs)
+	}
+	if graph.FindLine(true, []string{"d4"}) == nil {
+		t.Errorf("expected the games after the errors to be added")
+	}
+
+	// the handler stops the stream
+	graph, _ = NewPositionGraph(3)
+	added, err = graph.AddStream(newStream(), func(err error) error {
+		if errors.Is(err, ErrInvalidGame) {
+			return nil
+		}
+		return err
+	})
+	if !errors.Is(err, errFetching) || added != 1 {
+		t.Errorf("expected \"%v\" after 1 added game, got \"%v\" after %v", errFetching, err, added)
+	}
+	if graph.FindLine(true, []string{"d4"}) != nil {
+		t.Errorf("expected the games after the error not to be added")
+	}
+
+	// without a handler the first error stops the stream
+	graph, _ = NewPositionGraph(3)
+	if added, err = graph.AddStream(newStream(), nil); !errors.Is(err, ErrInvalidGame) || added != 1 {
+		t.Errorf("expected \"%v\" after 1 added game, got \"%v\" after %v", ErrInvalidGame, err, added)
+	}
+}
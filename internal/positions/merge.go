@@ -0,0 +1,147 @@
+package positions
+
+import "fmt"
+
+// Conflict is a difference between the graphs passed to Merge that had to be resolved
+type Conflict struct {
+	// Graph is the index of the graph that conflicts with the ones merged before it
+	Graph int
+	// FEN is the position the conflict is about, empty for conflicts of whole graphs
+	FEN     FEN
+	Message string
+}
+
+// String implements fmt.Stringer interface
+func (c Conflict) String() string {
+	if c.FEN == "" {
+		return c.Message
+	}
+	return fmt.Sprintf("%v: %v", c.FEN, c.Message)
+}
+
+// Merge combines the graphs into a new one. Nodes of the same colour are united by Key,
+// the numbers of games and results of the same moves are added up and the play history is combined.
+// A position evaluated in several graphs keeps the deepest evaluation. The merged graph is as deep
+// as the deepest of the graphs. Different depths and evaluations made by different engines are reported
+// as conflicts. The graphs are left unchanged
+func Merge(graphs ...*PositionGraph) (*PositionGraph, []Conflict, error) {
+	if len(graphs) == 0 {
+		return nil, nil, fmt.Errorf("positions.Merge: no graphs to merge")
+	}
+	depth := graphs[0].Depth
+	for _, graph := range graphs[1:] {
+		if graph.Depth > depth {
+			depth = graph.Depth
+		}
+	}
+	merged, err := NewPositionGraph(depth)
+	if err != nil {
+		return nil, nil, fmt.Errorf("positions.Merge: %w", err)
+	}
+	conflicts := make([]Conflict, 0)
+	for i, graph := range graphs {
+		if i > 0 && graph.Depth != graphs[0].Depth {
+			conflicts = append(conflicts, Conflict{
+				Graph:   i,
+				Message: fmt.Sprintf("depth %v differs from %v, the merged graph has depth %v", graph.Depth, graphs[0].Depth, depth),
+			})
+		}
+		m := merger{graph: i, conflicts: conflicts}
+		for _, tree := range []struct {
+			root, mergedRoot *PositionNode
+			positionIndex    map[Key]*PositionNode
+		}{
+			{graph.WhitePositions, merged.WhitePositions, merged.WhitePositionIndex},
+			{graph.BlackPositions, merged.BlackPositions, merged.BlackPositionIndex},
+		} {
+			m.positionIndex = tree.positionIndex
+			m.visited = map[*PositionNode]bool{tree.root: true}
+			m.mergeNode(tree.mergedRoot, tree.root)
+		}
+		conflicts = m.conflicts
+	}
+	return merged, conflicts, nil
+}
+
+// merger adds the nodes of one of the graphs to a merged tree
+type merger struct {
+	graph         int
+	positionIndex map[Key]*PositionNode
+	visited       map[*PositionNode]bool
+	conflicts     []Conflict
+}
+
+// mergeNode adds the data and the moves of node to target, which represents the same position
+func (m *merger) mergeNode(target, node *PositionNode) {
+	if node == nil {
+		return
+	}
+	m.mergeHistory(target, node)
+	m.mergeEvaluation(target, node)
+	for _, move := range node.Moves {
+		next, found := m.positionIndex[move.To.Position.Key]
+		if !found {
+			next = &PositionNode{Position: &Position{FEN: move.To.Position.FEN, Key: move.To.Position.Key}}
+			m.positionIndex[move.To.Position.Key] = next
+		}
+		edge := target.findMove(move.Move)
+		if edge == nil {
+			edge = &Move{To: next, Move: move.Move}
+			target.Moves = append(target.Moves, edge)
+		}
+		edge.Played += move.Played
+		edge.Wins += move.Wins
+		edge.Draws += move.Draws
+		edge.Losses += move.Losses
+		extendDates(&edge.FirstPlayed, &edge.LastPlayed, move.FirstPlayed)
+		extendDates(&edge.FirstPlayed, &edge.LastPlayed, move.LastPlayed)
+		mergeMonths(&edge.MonthlyPlayed, move.MonthlyPlayed)
+		if !m.visited[move.To] {
+			m.visited[move.To] = true
+			m.mergeNode(next, move.To)
+		}
+	}
+}
+
+// mergeHistory combines the dates the position was reached at
+func (m *merger) mergeHistory(target, node *PositionNode) {
+	extendDates(&target.FirstPlayed, &target.LastPlayed, node.FirstPlayed)
+	extendDates(&target.FirstPlayed, &target.LastPlayed, node.LastPlayed)
+	mergeMonths(&target.MonthlyPlayed, node.MonthlyPlayed)
+}
+
+// mergeMonths adds a monthly history to the target one
+func mergeMonths(target *map[string]int, monthly map[string]int) {
+	if len(monthly) > 0 && *target == nil {
+		*target = make(map[string]int, len(monthly))
+	}
+	for month, played := range monthly {
+		(*target)[month] += played
+	}
+}
+
+// mergeEvaluation keeps the deeper of the evaluations of the position together with its alternatives
+func (m *merger) mergeEvaluation(target, node *PositionNode) {
+	if !node.Position.Evaluated {
+		return
+	}
+	current, other := target.Position.Evaluation, node.Position.Evaluation
+	if target.Position.Evaluated && current.Source != "" && other.Source != "" && current.Source != other.Source {
+		kept := current
+		if other.Depth > current.Depth {
+			kept = other
+		}
+		m.conflicts = append(m.conflicts, Conflict{
+			Graph: m.graph,
+			FEN:   target.Position.FEN,
+			Message: fmt.Sprintf("evaluated by %v (d%d) and %v (d%d), kept the evaluation of %v",
+				current.Source, current.Depth, other.Source, other.Depth, kept.Source),
+		})
+	}
+	if target.Position.Evaluated && current.Depth >= other.Depth {
+		return
+	}
+	target.Position.Evaluated = true
+	target.Position.Evaluation = other
+	target.Alternatives = append([]Evaluation(nil), node.Alternatives...)
+}
@@ -0,0 +1,110 @@
+package positions
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Hofsiedge/ChessOpeningAnalyzer/internal/fetching"
+)
+
+func TestMerge(t *testing.T) {
+	march := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	july := time.Date(2021, 7, 8, 0, 0, 0, 0, time.UTC)
+	first, _ := NewPositionGraph(2)
+	second, _ := NewPositionGraph(3)
+	for _, game := range []struct {
+		graph *PositionGraph
+		game  fetching.UserGame
+	}{
+		{first, fetching.UserGame{White: true, EndTime: march, Moves: []string{"e4", "e5"}, Result: fetching.Win}},
+		{first, fetching.UserGame{White: false, EndTime: march, Moves: []string{"d4", "d5"}, Result: fetching.Loss}},
+		{second, fetching.UserGame{White: true, EndTime: july, Moves: []string{"e4", "e5", "Nf3"}, Result: fetching.Draw}},
+		{second, fetching.UserGame{White: true, EndTime: july, Moves: []string{"e4", "c5", "Nf3"}, Result: fetching.Win}},
+	} {
+		if err := game.graph.AddGame(game.game); err != nil {
+			t.Fatal(err)
+		}
+	}
+	first.WhitePositions.Moves[0].To.Position.Evaluated = true
+	first.WhitePositions.Moves[0].To.Position.Evaluation = Evaluation{Centipawns: 30, Depth: 20, Source: "lichess"}
+	second.WhitePositions.Moves[0].To.Position.Evaluated = true
+	second.WhitePositions.Moves[0].To.Position.Evaluation = Evaluation{Centipawns: 25, Depth: 24, Source: "Stockfish 16"}
+	second.WhitePositions.Moves[0].To.Alternatives = []Evaluation{{Centipawns: 25, BestMove: "e5", Source: "Stockfish 16"}}
+
+	merged, conflicts, err := Merge(first, second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merged.Depth != 3 {
+		t.Errorf("expected the depth of the deepest graph, got %v", merged.Depth)
+	}
+	expectedConflicts := []Conflict{{
+		Graph:   1,
+		Message: "depth 3 differs from 2, the merged graph has depth 3",
+	}, {
+		Graph:   1,
+		FEN:     first.WhitePositions.Moves[0].To.Position.FEN,
+		Message: "evaluated by lichess (d20) and Stockfish 16 (d24), kept the evaluation of Stockfish 16",
+	}}
+	if !reflect.DeepEqual(conflicts, expectedConflicts) {
+		t.Errorf("expected conflicts %v, got %v", expectedConflicts, conflicts)
+	}
+
+	e4 := merged.WhitePositions.Moves[0]
+	if e4.Played != 3 || e4.Wins != 2 || e4.Draws != 1 || e4.Losses != 0 {
+		t.Errorf("expected the statistics of e4 to be added up, got %+v", e4)
+	}
+	if e := e4.To.Position.Evaluation; e.Source != "Stockfish 16" || e.Depth != 24 || len(e4.To.Alternatives) != 1 {
+		t.Errorf("expected the deeper evaluation to be kept, got %+v", e)
+	}
+	if !e4.To.FirstPlayed.Equal(march) || !e4.To.LastPlayed.Equal(july) {
+		t.Errorf("expected e4 to be played from %v to %v, got %v - %v", march, july, e4.To.FirstPlayed, e4.To.LastPlayed)
+	}
+	if expected := map[string]int{"2021-03": 1, "2021-07": 2}; !reflect.DeepEqual(e4.To.MonthlyPlayed, expected) {
+		t.Errorf("expected monthly counts %v, got %v", expected, e4.To.MonthlyPlayed)
+	}
+	if len(merged.BlackPositions.Moves) != 1 || merged.BlackPositions.Moves[0].Losses != 1 {
+		t.Errorf("expected the black repertoire of the first graph, got %v", merged.BlackPositions.Moves)
+	}
+	if len(merged.WhitePositionIndex) != 5 || len(merged.BlackPositionIndex) != 2 {
+		t.Errorf("unexpected indexes: %v white and %v black positions", len(merged.WhitePositionIndex), len(merged.BlackPositionIndex))
+	}
+	if first.WhitePositions.Moves[0].Played != 1 {
+		t.Errorf("expected the merged graphs to be left unchanged, got %+v", first.WhitePositions.Moves[0])
+	}
+
+	expected := `└─── e4    -> +0.25 (d24)
+      ├─── e5
+      │     └─── Nf3
+      └─── c5
+            └─── Nf3
+`
+	if got := merged.WhitePositions.String(); got != expected {
+		t.Errorf("expected:\n%v\ngot:\n%v", expected, got)
+	}
+}
+
+func TestMerge_Transpositions(t *testing.T) {
+	graphs := make([]*PositionGraph, 2)
+	for i, moves := range []string{"e4 e5 Nf3 Nc6", "Nf3 Nc6 e4 e5"} {
+		graphs[i], _ = NewPositionGraph(4)
+		if err := graphs[i].AddGame(fetching.UserGame{White: true, Moves: strings.Split(moves, " ")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	merged, conflicts, err := Merge(graphs...)
+	if err != nil || len(conflicts) != 0 {
+		t.Fatalf("unexpected result: %v, %v", conflicts, err)
+	}
+	viaE4 := merged.WhitePositions.Moves[0].To.Moves[0].To.Moves[0].To.Moves[0].To
+	viaNf3 := merged.WhitePositions.Moves[1].To.Moves[0].To.Moves[0].To.Moves[0].To
+	if viaE4 != viaNf3 {
+		t.Errorf("expected both move orders to lead to the same node, got %v and %v", viaE4, viaNf3)
+	}
+
+	if _, _, err := Merge(); err == nil {
+		t.Error("expected an error merging no graphs")
+	}
+}
@@ -0,0 +1,103 @@
+package positions
+
+import (
+	"fmt"
+	"time"
+)
+
+// PruneOptions tell Prune which moves to drop. Zero values keep everything
+type PruneOptions struct {
+	// Depth is the number of half-moves to keep, it has to be between 2 and the depth of the graph.
+	// A position reached by several move orders keeps its moves if the shortest of them is short enough
+	Depth int
+	// MinPlayed drops the moves played in fewer games
+	MinPlayed int
+	// Since drops the lines not played since the date
+	Since time.Time
+}
+
+// Prune removes moves from the graph in place and returns the number of removed positions.
+// The position indexes are kept consistent with the trees and the graph gets the new depth
+func (g *PositionGraph) Prune(opts PruneOptions) (int, error) {
+	if opts.Depth != 0 && (opts.Depth <= 1 || opts.Depth > g.Depth) {
+		return 0, fmt.Errorf("positions.Prune: expected 1 < depth <= %v, got: %v", g.Depth, opts.Depth)
+	}
+	removed := 0
+	for _, root := range []*PositionNode{g.WhitePositions, g.BlackPositions} {
+		visited := map[*PositionNode]bool{root: true}
+		root.filterMoves(opts, visited)
+		if opts.Depth != 0 {
+			root.cutDepth(opts.Depth)
+		}
+	}
+	for _, tree := range []struct {
+		root          *PositionNode
+		positionIndex map[Key]*PositionNode
+	}{{g.WhitePositions, g.WhitePositionIndex}, {g.BlackPositions, g.BlackPositionIndex}} {
+		reachable := tree.root.reachable()
+		for key, node := range tree.positionIndex {
+			if !reachable[node] {
+				delete(tree.positionIndex, key)
+				removed++
+			}
+		}
+	}
+	if opts.Depth != 0 {
+		g.Depth = opts.Depth
+	}
+	return removed, nil
+}
+
+// filterMoves drops the moves of the node and of the following positions that were played too rarely or too long ago
+func (n *PositionNode) filterMoves(opts PruneOptions, visited map[*PositionNode]bool) {
+	moves := n.Moves[:0]
+	for _, move := range n.Moves {
+		if move.Played < opts.MinPlayed || (!opts.Since.IsZero() && !move.PlayedBetween(opts.Since, time.Time{})) {
+			continue
+		}
+		moves = append(moves, move)
+		if !visited[move.To] {
+			visited[move.To] = true
+			move.To.filterMoves(opts, visited)
+		}
+	}
+	n.Moves = moves
+}
+
+// cutDepth drops the moves of the positions that are at least depth half-moves away from the node
+// along the shortest move order
+func (n *PositionNode) cutDepth(depth int) {
+	plies := map[*PositionNode]int{n: 0}
+	queue := []*PositionNode{n}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		if plies[node] >= depth {
+			node.Moves = nil
+			continue
+		}
+		for _, move := range node.Moves {
+			if _, found := plies[move.To]; !found {
+				plies[move.To] = plies[node] + 1
+				queue = append(queue, move.To)
+			}
+		}
+	}
+}
+
+// reachable returns the set of nodes that can be reached from the node, including itself
+func (n *PositionNode) reachable() map[*PositionNode]bool {
+	nodes := map[*PositionNode]bool{n: true}
+	queue := []*PositionNode{n}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		for _, move := range node.Moves {
+			if !nodes[move.To] {
+				nodes[move.To] = true
+				queue = append(queue, move.To)
+			}
+		}
+	}
+	return nodes
+}
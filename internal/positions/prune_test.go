@@ -0,0 +1,141 @@
+package positions
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Hofsiedge/ChessOpeningAnalyzer/internal/fetching"
+)
+
+func newPruneGraph(t *testing.T) *PositionGraph {
+	graph, _ := NewPositionGraph(4)
+	for _, game := range []struct {
+		date  time.Time
+		moves string
+	}{
+		{time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC), "e4 c5 Nf3 d6"},
+		{time.Date(2021, 7, 8, 0, 0, 0, 0, time.UTC), "e4 e5 Nf3 Nc6"},
+		{time.Date(2021, 7, 9, 0, 0, 0, 0, time.UTC), "e4 e5 Nf3 Nf6"},
+		{time.Date(2021, 7, 10, 0, 0, 0, 0, time.UTC), "Nf3 Nc6 e4 e5"},
+	} {
+		if err := graph.AddGame(fetching.UserGame{White: true, EndTime: game.date, Moves: strings.Split(game.moves, " ")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return graph
+}
+
+// checkIndex verifies that the index of the white repertoire contains exactly the nodes of the tree
+func checkIndex(t *testing.T, graph *PositionGraph) {
+	t.Helper()
+	reachable := graph.WhitePositions.reachable()
+	if len(reachable) != len(graph.WhitePositionIndex)+1 {
+		t.Errorf("expected %v positions in the index, got %v", len(reachable)-1, len(graph.WhitePositionIndex))
+	}
+	for key, node := range graph.WhitePositionIndex {
+		if !reachable[node] || node.Position.Key != key {
+			t.Errorf("unexpected node in the index: %v", node.Position.FEN)
+		}
+	}
+}
+
+func TestPositionGraph_Prune(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    PruneOptions
+		removed int
+		want    string
+	}{{
+		name:    "Depth",
+		opts:    PruneOptions{Depth: 2},
+		removed: 6,
+		want: `├─── e4
+│     ├─── c5
+│     └─── e5
+└─── Nf3
+      └─── Nc6
+`,
+	}, {
+		name:    "MinPlayed",
+		opts:    PruneOptions{MinPlayed: 2},
+		removed: 8,
+		want: `└─── e4
+      └─── e5
+            └─── Nf3
+`,
+	}, {
+		name:    "Since",
+		opts:    PruneOptions{Since: time.Date(2021, 7, 1, 0, 0, 0, 0, time.UTC)},
+		removed: 3,
+		want: `├─── e4
+│     └─── e5
+│           └─── Nf3
+│                 ├─── Nc6
+│                 └─── Nf6
+└─── Nf3
+      └─── Nc6
+            └─── e4
+                  └─── e5 (transposition)
+`,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			graph := newPruneGraph(t)
+			removed, err := graph.Prune(tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if removed != tt.removed {
+				t.Errorf("expected %v removed positions, got %v", tt.removed, removed)
+			}
+			if got := graph.WhitePositions.String(); got != tt.want {
+				t.Errorf("expected:\n%v\ngot:\n%v", tt.want, got)
+			}
+			checkIndex(t, graph)
+		})
+	}
+
+	graph := newPruneGraph(t)
+	for _, depth := range []int{1, 5} {
+		if _, err := graph.Prune(PruneOptions{Depth: depth}); err == nil {
+			t.Errorf("expected an error pruning to depth %v", depth)
+		}
+	}
+	if _, err := graph.Prune(PruneOptions{Depth: 3}); err != nil || graph.Depth != 3 {
+		t.Errorf("expected the graph to get depth 3, got %v (%v)", graph.Depth, err)
+	}
+}
+
+func TestPositionGraph_Prune_Transposition(t *testing.T) {
+	graph, _ := NewPositionGraph(4)
+	for _, game := range []struct {
+		date  time.Time
+		moves string
+	}{
+		{time.Date(2021, 7, 8, 0, 0, 0, 0, time.UTC), "d4 d5 Nf3 Nf6"},
+		{time.Date(2021, 7, 9, 0, 0, 0, 0, time.UTC), "d4 e6 Nf3 d5"},
+		// 3... e6 reaches the position after 3... d5 of the previous game, but it was not played since July
+		{time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC), "d4 d5 Nf3 e6"},
+	} {
+		if err := graph.AddGame(fetching.UserGame{White: true, EndTime: game.date, Moves: strings.Split(game.moves, " ")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	removed, err := graph.Prune(PruneOptions{Since: time.Date(2021, 7, 1, 0, 0, 0, 0, time.UTC)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `└─── d4
+      ├─── d5
+      │     └─── Nf3
+      │           └─── Nf6
+      └─── e6
+            └─── Nf3
+                  └─── d5
+`
+	if got := graph.WhitePositions.String(); removed != 0 || got != want {
+		t.Errorf("expected to drop 3... e6 only, got %v removed positions and:\n%v", removed, got)
+	}
+	checkIndex(t, graph)
+}
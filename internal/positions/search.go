@@ -0,0 +1,110 @@
+package positions
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/notnil/chess"
+)
+
+// Path is a move order from the starting position of a repertoire
+type Path struct {
+	White bool
+	Moves []*Move
+	// Played is the number of games that followed the path. Games are only counted per move,
+	// so if a position of the path is reached by other move orders too, it is the smallest count of its moves
+	Played int
+	// FirstPlayed and LastPlayed narrow the dates of the moves of the path down in the same way.
+	// They are zero if the dates of the moves do not overlap, so no game followed the whole path
+	FirstPlayed time.Time
+	LastPlayed  time.Time
+}
+
+// newPath summarizes the moves of a path
+func newPath(white bool, moves []*Move) Path {
+	path := Path{White: white, Moves: moves}
+	for i, move := range moves {
+		if i == 0 || move.Played < path.Played {
+			path.Played = move.Played
+		}
+		if move.FirstPlayed.After(path.FirstPlayed) {
+			path.FirstPlayed = move.FirstPlayed
+		}
+		if !move.LastPlayed.IsZero() && (path.LastPlayed.IsZero() || move.LastPlayed.Before(path.LastPlayed)) {
+			path.LastPlayed = move.LastPlayed
+		}
+	}
+	if path.FirstPlayed.After(path.LastPlayed) {
+		path.FirstPlayed, path.LastPlayed = time.Time{}, time.Time{}
+	}
+	return path
+}
+
+// SAN returns the moves of the path in SAN
+func (p Path) SAN() []string {
+	moves := make([]string, len(p.Moves))
+	for i, move := range p.Moves {
+		moves[i] = move.Move
+	}
+	return moves
+}
+
+// String implements fmt.Stringer interface: `1. e4 c5 2. Nf3 [12 games] (01.02.2021 - 08.07.2021)`
+func (p Path) String() string {
+	games := "games"
+	if p.Played == 1 {
+		games = "game"
+	}
+	return fmt.Sprintf("%v [%d %v]%v", FormatMoves(p.SAN()), p.Played, games, formatDates(p.FirstPlayed, p.LastPlayed))
+}
+
+// LinePosition returns the position reached by the moves in SAN played from the starting position
+func LinePosition(moves []string) (*Position, error) {
+	board := chess.StartingPosition()
+	for _, move := range moves {
+		var err error
+		if board, err = playMove(board, move); err != nil {
+			return nil, fmt.Errorf("positions.LinePosition: %w", err)
+		}
+	}
+	return &Position{FEN: positionFEN(board), Key: NewKey(board)}, nil
+}
+
+// Search returns every move order of the white or the black repertoire that reaches the position
+// without repeating a position, the most played ones first
+func (g *PositionGraph) Search(white bool, key Key) []Path {
+	paths := make([]Path, 0)
+	target := g.FindPosition(white, key)
+	if target == nil {
+		return paths
+	}
+	root := g.BlackPositions
+	if white {
+		root = g.WhitePositions
+	}
+	if target == root {
+		return append(paths, Path{White: white, Played: playedFrom(root), FirstPlayed: root.FirstPlayed, LastPlayed: root.LastPlayed})
+	}
+	var search func(node *PositionNode, moves []*Move, onPath map[*PositionNode]bool)
+	search = func(node *PositionNode, moves []*Move, onPath map[*PositionNode]bool) {
+		for _, move := range node.Moves {
+			if onPath[move.To] {
+				continue
+			}
+			next := append(moves[:len(moves):len(moves)], move)
+			if move.To == target {
+				paths = append(paths, newPath(white, next))
+				continue
+			}
+			onPath[move.To] = true
+			search(move.To, next, onPath)
+			delete(onPath, move.To)
+		}
+	}
+	search(root, nil, map[*PositionNode]bool{root: true})
+	sort.SliceStable(paths, func(i, j int) bool {
+		return paths[i].Played > paths[j].Played
+	})
+	return paths
+}
@@ -0,0 +1,89 @@
+package positions
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Hofsiedge/ChessOpeningAnalyzer/internal/fetching"
+)
+
+func TestPositionGraph_Search(t *testing.T) {
+	graph, _ := NewPositionGraph(4)
+	for _, game := range []struct {
+		date  time.Time
+		moves string
+	}{
+		{time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC), "e4 e5 Nf3 Nc6"},
+		{time.Date(2021, 7, 8, 0, 0, 0, 0, time.UTC), "e4 e5 Nf3 Nc6"},
+		{time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC), "Nf3 Nc6 e4 e5"},
+		{time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC), "e4 Nc6 Nf3 e5"},
+		{time.Date(2021, 6, 2, 0, 0, 0, 0, time.UTC), "e4 Nc6 d4 e5"},
+	} {
+		if err := graph.AddGame(fetching.UserGame{White: true, EndTime: game.date, Moves: strings.Split(game.moves, " ")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	position, err := LinePosition([]string{"Nf3", "Nc6", "e4", "e5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := graph.Search(true, position.Key)
+	expected := []string{
+		"1. e4 e5 2. Nf3 Nc6 [2 games] (01.03.2021 - 08.07.2021)",
+		"1. e4 Nc6 2. Nf3 e5 [1 game] (01.06.2021)",
+		"1. Nf3 Nc6 2. e4 e5 [1 game] (01.05.2021)",
+	}
+	if len(paths) != len(expected) {
+		t.Fatalf("expected %v paths, got %v", len(expected), paths)
+	}
+	for i, path := range paths {
+		if got := path.String(); got != expected[i] || !path.White {
+			t.Errorf("expected path %q, got %q", expected[i], got)
+		}
+	}
+
+	if paths := graph.Search(false, position.Key); len(paths) != 0 {
+		t.Errorf("expected no paths in the black repertoire, got %v", paths)
+	}
+	root := graph.Search(true, graph.WhitePositions.Position.Key)
+	if len(root) != 1 || len(root[0].Moves) != 0 || root[0].Played != 5 {
+		t.Errorf("expected the empty path to the starting position, got %v", root)
+	}
+	if _, err := LinePosition([]string{"e4", "e4"}); err == nil {
+		t.Error("expected an error for an illegal move")
+	}
+}
+
+func TestPositionGraph_Search_Dates(t *testing.T) {
+	graph, _ := NewPositionGraph(4)
+	for _, game := range []struct {
+		date  time.Time
+		moves string
+	}{
+		{time.Date(2021, 1, 5, 0, 0, 0, 0, time.UTC), "c4 e6 d4 d5"},
+		{time.Date(2021, 8, 5, 0, 0, 0, 0, time.UTC), "d4 e6 c4 Nf6"},
+	} {
+		if err := graph.AddGame(fetching.UserGame{White: true, EndTime: game.date, Moves: strings.Split(game.moves, " ")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	position, err := LinePosition([]string{"c4", "e6", "d4", "d5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 3... d5 was only played in January, the moves before it only in August
+	expected := []string{
+		"1. c4 e6 2. d4 d5 [1 game] (05.01.2021)",
+		"1. d4 e6 2. c4 d5 [1 game]",
+	}
+	paths := graph.Search(true, position.Key)
+	if len(paths) != len(expected) {
+		t.Fatalf("expected %v paths, got %v", len(expected), paths)
+	}
+	for i, path := range paths {
+		if got := path.String(); got != expected[i] {
+			t.Errorf("expected path %q, got %q", expected[i], got)
+		}
+	}
+}
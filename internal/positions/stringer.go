@@ -5,49 +5,135 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"time"
 )
 
 // String implements fmt.Stringer interface
 func (m *Move) String() string {
 	score := ""
 	if p := m.To.Position; p.Evaluated {
-		score = fmt.Sprintf(" -> %v", m.To.Position.Score)
+		score = fmt.Sprintf(" -> %v", p.Evaluation.WithPerspective(WhitePerspective))
 	}
 	return strings.Trim(fmt.Sprintf("%-5v%v", m.Move, score), " ")
 }
 
+// PrintOptions control what is printed next to the moves of a move tree
+type PrintOptions struct {
+	// Dates prints the dates of the first and the last games next to every move
+	Dates bool
+	// Since and Until hide the moves that were not played within the window, zero values leave it open
+	Since time.Time
+	Until time.Time
+	// Alternatives prints the engine's moves that are better than the played ones
+	Alternatives bool
+	// Stats prints the number of games and the user's results after every move
+	Stats bool
+	// MaxDepth limits the number of half-moves printed below the starting node, zero means no limit
+	MaxDepth int
+}
+
+// formatStats summarizes the games played through the move: `[12 games, +5 =3 -4]`
+func formatStats(m *Move) string {
+	games := "games"
+	if m.Played == 1 {
+		games = "game"
+	}
+	return fmt.Sprintf(" [%d %v, +%d =%d -%d]", m.Played, games, m.Wins, m.Draws, m.Losses)
+}
+
+// FormatMoves formats a sequence of moves in SAN played from the starting position: `1. e4 e5 2. Nf3`
+func FormatMoves(moves []string) string {
+	words := make([]string, 0, len(moves)*3/2+1)
+	for i, move := range moves {
+		if i%2 == 0 {
+			words = append(words, fmt.Sprintf("%d.", i/2+1))
+		}
+		words = append(words, move)
+	}
+	return strings.Join(words, " ")
+}
+
+// SplitMoves is the reverse of FormatMoves, it also accepts moves without numbers: `e4 e5 Nf3`
+func SplitMoves(line string) []string {
+	moves := make([]string, 0)
+	for _, word := range strings.Fields(line) {
+		if move := strings.TrimLeft(word, "0123456789."); move != "" {
+			moves = append(moves, move)
+		}
+	}
+	return moves
+}
+
 // String implements fmt.Stringer interface
 func (n *PositionNode) String() string {
-	return n.Print(false)
+	return n.Print(PrintOptions{})
 }
 
-func (n *PositionNode) Print(printDate bool) string {
+// Print draws the move tree of the node. The moves of a position reached by several move orders
+// are printed once, under its first occurrence; the other ones are marked as transpositions
+func (n *PositionNode) Print(opts PrintOptions) string {
 	buffer := new(bytes.Buffer)
-	n.print(buffer, "", printDate)
+	n.print(buffer, "", opts, map[*PositionNode]bool{n: true})
 	return buffer.String()
 }
 
 // String implements fmt.Stringer interface
 func (g *PositionGraph) String() string {
-	return g.Print(false)
+	return g.Print(PrintOptions{})
 }
 
-func (g *PositionGraph) Print(printDates bool) string {
+func (g *PositionGraph) Print(opts PrintOptions) string {
 	lines := make([]string, 0)
 	lines = append(lines, "Position graph.", fmt.Sprintf("Depth: %v", g.Depth))
-	if len(g.WhitePositions.Moves) > 0 {
-		lines = append(lines, fmt.Sprintf("White positions:\n%v", g.WhitePositions.Print(printDates)))
+	if len(g.WhitePositions.visibleMoves(opts)) > 0 {
+		lines = append(lines, fmt.Sprintf("White positions:\n%v", g.WhitePositions.Print(opts)))
 	}
-	if len(g.BlackPositions.Moves) > 0 {
-		lines = append(lines, fmt.Sprintf("Black positions:\n%v", g.BlackPositions.Print(printDates)))
+	if len(g.BlackPositions.visibleMoves(opts)) > 0 {
+		lines = append(lines, fmt.Sprintf("Black positions:\n%v", g.BlackPositions.Print(opts)))
 	}
 	return strings.Join(lines, "\n")
 }
 
-func (n *PositionNode) print(out io.Writer, prefix string, printDate bool) {
-	lastMoveIndex := len(n.Moves) - 1
+// formatAlternatives lists the engine's moves like `Nc3 +0.45, d4 +0.40`
+func formatAlternatives(alternatives []Evaluation) string {
+	moves := make([]string, len(alternatives))
+	for i, alternative := range alternatives {
+		moves[i] = fmt.Sprintf("%v %v", alternative.BestMove, alternative.WithPerspective(WhitePerspective).Score())
+	}
+	return strings.Join(moves, ", ")
+}
+
+// formatDates formats the dates of the first and the last games: ` (01.02.2021 - 08.07.2021)`
+func formatDates(first, last time.Time) string {
+	const layout = "02.01.2006"
+	if last.IsZero() {
+		return ""
+	}
+	if first.IsZero() || first.Format(layout) == last.Format(layout) {
+		return fmt.Sprintf(" (%v)", last.Format(layout))
+	}
+	return fmt.Sprintf(" (%v - %v)", first.Format(layout), last.Format(layout))
+}
+
+// visibleMoves returns the moves of the node that were played within the window of opts
+func (n *PositionNode) visibleMoves(opts PrintOptions) []*Move {
+	if opts.Since.IsZero() && opts.Until.IsZero() {
+		return n.Moves
+	}
+	moves := make([]*Move, 0, len(n.Moves))
+	for _, move := range n.Moves {
+		if move.PlayedBetween(opts.Since, opts.Until) {
+			moves = append(moves, move)
+		}
+	}
+	return moves
+}
+
+func (n *PositionNode) print(out io.Writer, prefix string, opts PrintOptions, printed map[*PositionNode]bool) {
+	moves := n.visibleMoves(opts)
+	lastMoveIndex := len(moves) - 1
 	var leftBorder, movePrefix string
-	for i, move := range n.Moves {
+	for i, move := range moves {
 		if i == lastMoveIndex {
 			leftBorder = " "
 			movePrefix = "└───"
@@ -55,15 +141,38 @@ func (n *PositionNode) print(out io.Writer, prefix string, printDate bool) {
 			leftBorder = "│"
 			movePrefix = "├───"
 		}
+		stats := ""
+		if opts.Stats {
+			stats = formatStats(move)
+		}
 		date := ""
-		if printDate && len(move.To.Moves) == 0 {
-			date = move.To.LastPlayed.Format(" (02.01.2006)")
+		if opts.Dates {
+			date = formatDates(move.dates())
+		}
+		alternatives := ""
+		if opts.Alternatives {
+			if better := n.BetterAlternatives(move); len(better) > 0 {
+				alternatives = fmt.Sprintf(" (better: %v)", formatAlternatives(better))
+			}
+		}
+		transposition := ""
+		if printed[move.To] {
+			transposition = " (transposition)"
+		}
+		_, _ = fmt.Fprintf(out, "%v %v%v%v%v%v\n", prefix+movePrefix, move, stats, date, alternatives, transposition)
+		if printed[move.To] || opts.MaxDepth == 1 {
+			continue
+		}
+		printed[move.To] = true
+		nextOpts := opts
+		if nextOpts.MaxDepth > 0 {
+			nextOpts.MaxDepth--
 		}
-		_, _ = fmt.Fprintf(out, "%v %v%v\n", prefix+movePrefix, move, date)
 		move.To.print(
 			out,
 			prefix+leftBorder+"     ",
-			printDate,
+			nextOpts,
+			printed,
 		)
 	}
 }
@@ -3,6 +3,8 @@ package positions
 import (
 	"bytes"
 	"fmt"
+	"github.com/Hofsiedge/ChessOpeningAnalyzer/internal/fetching"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -12,7 +14,6 @@ func TestMove_String(t *testing.T) {
 		To: &PositionNode{
 			Position: &Position{
 				FEN:       "",
-				Score:     0,
 				Evaluated: false,
 			},
 			LastPlayed: time.Time{},
@@ -24,21 +25,30 @@ func TestMove_String(t *testing.T) {
 		t.Errorf("Expect \"e4\", got \"%v\"", moveStr)
 	}
 	move.To.Position.Evaluated = true
-	move.To.Position.Score = 1.3
-	if moveStr := fmt.Sprint(move); moveStr != "e4    -> 1.3" {
-		t.Errorf("Expect \"e4    -> 1.3\", got \"%v\"", moveStr)
+	move.To.Position.Evaluation = Evaluation{Centipawns: 130, Depth: 24}
+	if moveStr := fmt.Sprint(move); moveStr != "e4    -> +1.30 (d24)" {
+		t.Errorf("Expect \"e4    -> +1.30 (d24)\", got \"%v\"", moveStr)
+	}
+	move.To.Position.Evaluation = Evaluation{
+		Perspective: SideToMovePerspective,
+		Mate:        true,
+		MateIn:      3,
+	}
+	if moveStr := fmt.Sprint(move); moveStr != "e4    -> #-3" {
+		t.Errorf("Expect \"e4    -> #-3\", got \"%v\"", moveStr)
 	}
 }
 
 func TestPositionNode_print(t *testing.T) {
 	type fields struct {
-		Position   *Position
-		LastPlayed time.Time
-		Moves      []*Move
+		Position     *Position
+		LastPlayed   time.Time
+		Moves        []*Move
+		Alternatives []Evaluation
 	}
 	type args struct {
-		prefix    string
-		printDate bool
+		prefix string
+		opts   PrintOptions
 	}
 	tests := []struct {
 		name    string
@@ -50,7 +60,6 @@ func TestPositionNode_print(t *testing.T) {
 		fields: fields{
 			Position: &Position{
 				FEN:       "",
-				Score:     0,
 				Evaluated: false,
 			},
 			LastPlayed: time.Time{},
@@ -70,22 +79,176 @@ func TestPositionNode_print(t *testing.T) {
 				Move: "d4",
 			}},
 		},
-		args:    args{"", false},
+		args:    args{"", PrintOptions{}},
+		wantOut: "├─── e4\n└─── d4\n",
+	}, {
+		name: "TestAlternativesPositionNodeString",
+		fields: fields{
+			Position: &Position{FEN: "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq"},
+			Moves: []*Move{{
+				To: &PositionNode{
+					Position: &Position{Evaluated: true, Evaluation: Evaluation{Centipawns: 5, Depth: 20}},
+				},
+				Move: "b3",
+			}},
+			Alternatives: []Evaluation{
+				{Centipawns: 35, BestMove: "e4"},
+				{Centipawns: 30, BestMove: "d4"},
+				{Centipawns: -50, BestMove: "g4"},
+			},
+		},
+		args:    args{"", PrintOptions{Alternatives: true}},
+		wantOut: "└─── b3    -> +0.05 (d20) (better: e4 +0.35, d4 +0.30)\n",
+	}, {
+		name: "TestStatsPositionNodeString",
+		fields: fields{
+			Position: &Position{},
+			Moves: []*Move{{
+				To:     &PositionNode{Position: &Position{}},
+				Move:   "e4",
+				Played: 12,
+				Wins:   5,
+				Draws:  3,
+				Losses: 3,
+			}, {
+				To:     &PositionNode{Position: &Position{}},
+				Move:   "d4",
+				Played: 1,
+			}},
+		},
+		args:    args{"", PrintOptions{Stats: true}},
+		wantOut: "├─── e4 [12 games, +5 =3 -3]\n└─── d4 [1 game, +0 =0 -0]\n",
+	}, {
+		name: "TestDatesPositionNodeString",
+		fields: fields{
+			Position: &Position{},
+			Moves: []*Move{{
+				To: &PositionNode{
+					Position:      &Position{},
+					FirstPlayed:   time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC),
+					LastPlayed:    time.Date(2021, 7, 8, 0, 0, 0, 0, time.UTC),
+					MonthlyPlayed: map[string]int{"2021-02": 1, "2021-07": 1},
+					Moves: []*Move{{
+						To: &PositionNode{
+							Position:      &Position{},
+							FirstPlayed:   time.Date(2021, 7, 8, 0, 0, 0, 0, time.UTC),
+							LastPlayed:    time.Date(2021, 7, 8, 0, 0, 0, 0, time.UTC),
+							MonthlyPlayed: map[string]int{"2021-07": 1},
+						},
+						Move: "e5",
+					}, {
+						To: &PositionNode{
+							Position:      &Position{},
+							FirstPlayed:   time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC),
+							LastPlayed:    time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC),
+							MonthlyPlayed: map[string]int{"2021-02": 1},
+						},
+						Move: "c5",
+					}},
+				},
+				Move: "e4",
+			}},
+		},
+		args:    args{"", PrintOptions{Dates: true, Since: time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)}},
+		wantOut: "└─── e4 (01.02.2021 - 08.07.2021)\n      └─── e5 (08.07.2021)\n",
+	}, {
+		name: "TestMaxDepthPositionNodeString",
+		fields: fields{
+			Position: &Position{},
+			Moves: []*Move{{
+				To: &PositionNode{
+					Position: &Position{},
+					Moves: []*Move{{
+						To:   &PositionNode{Position: &Position{}},
+						Move: "e5",
+					}},
+				},
+				Move: "e4",
+			}, {
+				To:   &PositionNode{Position: &Position{}},
+				Move: "d4",
+			}},
+		},
+		args:    args{"", PrintOptions{MaxDepth: 1}},
 		wantOut: "├─── e4\n└─── d4\n",
 	},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			n := &PositionNode{
-				Position:   tt.fields.Position,
-				LastPlayed: tt.fields.LastPlayed,
-				Moves:      tt.fields.Moves,
+				Position:     tt.fields.Position,
+				LastPlayed:   tt.fields.LastPlayed,
+				Moves:        tt.fields.Moves,
+				Alternatives: tt.fields.Alternatives,
 			}
 			out := &bytes.Buffer{}
-			n.print(out, tt.args.prefix, tt.args.printDate)
+			n.print(out, tt.args.prefix, tt.args.opts, map[*PositionNode]bool{})
 			if gotOut := out.String(); gotOut != tt.wantOut {
 				t.Errorf("print() = %v, want %v", gotOut, tt.wantOut)
 			}
 		})
 	}
 }
+
+func TestPositionGraph_Print_Window(t *testing.T) {
+	graph, _ := NewPositionGraph(3)
+	// both move orders reach the same position, 3. c4 was played in February only
+	for _, game := range []fetching.UserGame{
+		{White: true, EndTime: time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC), Moves: []string{"Nf3", "Nf6", "c4"}},
+		{White: true, EndTime: time.Date(2021, 7, 8, 0, 0, 0, 0, time.UTC), Moves: []string{"c4", "Nf6", "Nf3"}},
+		{White: true, EndTime: time.Date(2021, 7, 8, 0, 0, 0, 0, time.UTC), Moves: []string{"Nf3", "Nf6", "g3"}},
+	} {
+		if err := graph.AddGame(game); err != nil {
+			t.Fatal(err)
+		}
+	}
+	opts := PrintOptions{Since: time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)}
+	if got := graph.WhitePositions.Print(opts); got != "├─── Nf3\n│     └─── Nf6\n│           └─── g3\n└─── c4\n      └─── Nf6\n            └─── Nf3\n" {
+		t.Errorf("expected the moves played since June only, got:\n%v", got)
+	}
+	opts = PrintOptions{Until: time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)}
+	if got := graph.WhitePositions.Print(opts); got != "└─── Nf3\n      └─── Nf6\n            └─── c4\n" {
+		t.Errorf("expected the moves played until March only, got:\n%v", got)
+	}
+	// the dates are the dates of the moves rather than the dates of the positions they lead to
+	expected := `├─── Nf3 (01.02.2021 - 08.07.2021)
+│     └─── Nf6 (01.02.2021 - 08.07.2021)
+│           ├─── c4 (01.02.2021)
+│           └─── g3 (08.07.2021)
+└─── c4 (08.07.2021)
+      └─── Nf6 (08.07.2021)
+            └─── Nf3 (08.07.2021) (transposition)
+`
+	if got := graph.WhitePositions.Print(PrintOptions{Dates: true}); got != expected {
+		t.Errorf("expected:\n%v\ngot:\n%v", expected, got)
+	}
+}
+
+func TestFormatMoves(t *testing.T) {
+	tests := map[string][]string{
+		"":                   nil,
+		"1. e4":              {"e4"},
+		"1. e4 e5 2. Nf3":    {"e4", "e5", "Nf3"},
+		"1. d4 Nf6 2. c4 e6": {"d4", "Nf6", "c4", "e6"},
+	}
+	for want, moves := range tests {
+		if got := FormatMoves(moves); got != want {
+			t.Errorf("expected %q, got %q", want, got)
+		}
+	}
+}
+
+func TestSplitMoves(t *testing.T) {
+	tests := map[string][]string{
+		"":                   {},
+		"e4 c5 Nf3":          {"e4", "c5", "Nf3"},
+		"1. e4 c5 2. Nf3":    {"e4", "c5", "Nf3"},
+		"1.d4 Nf6 2.c4 e6":   {"d4", "Nf6", "c4", "e6"},
+		" 1. e4  1... e5 \n": {"e4", "e5"},
+	}
+	for line, want := range tests {
+		if got := SplitMoves(line); !reflect.DeepEqual(got, want) {
+			t.Errorf("SplitMoves(%q) = %q, want %q", line, got, want)
+		}
+	}
+}
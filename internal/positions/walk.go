@@ -0,0 +1,202 @@
+package positions
+
+import (
+	"context"
+	"errors"
+)
+
+// SkipMoves is returned by Visitor.Pre to skip the moves after the current one. It is not returned by Walk
+var SkipMoves = errors.New("skip moves")
+
+// Step is a move reached by Walk
+type Step struct {
+	White bool
+	// From is the position the move is played from
+	From *PositionNode
+	// Path is the move order from the starting position, the current move is the last one.
+	// Walk reuses it, so it has to be copied to be kept after the hook returns
+	Path []*Move
+}
+
+// Move returns the current move
+func (s Step) Move() *Move {
+	return s.Path[len(s.Path)-1]
+}
+
+// Depth returns the number of half-moves from the starting position including the current one
+func (s Step) Depth() int {
+	return len(s.Path)
+}
+
+// Visitor is called by Walk for every move. Either of the hooks may be nil
+type Visitor struct {
+	// Pre is called before the moves after the current one are walked
+	Pre func(Step) error
+	// Post is called after the moves after the current one are walked, even if Pre returned SkipMoves
+	Post func(Step) error
+}
+
+// WalkOptions control which moves Walk visits. Zero values visit every move order of both repertoires
+type WalkOptions struct {
+	// MaxDepth is the number of half-moves to walk, zero means no limit
+	MaxDepth int
+	// Once walks the moves of every position once, under the first move order that reaches it.
+	// Otherwise the moves of a position are walked for each move order, except for the ones that repeat a position
+	Once bool
+	// SkipWhite and SkipBlack leave out the corresponding repertoire
+	SkipWhite bool
+	SkipBlack bool
+}
+
+// Walk visits the moves of the graph depth-first in the order they were added: the white repertoire first,
+// then the black one. It stops at the first error returned by a hook or when ctx is done and returns that error
+func (g *PositionGraph) Walk(ctx context.Context, visitor Visitor, opts WalkOptions) error {
+	for _, root := range []struct {
+		node  *PositionNode
+		white bool
+		skip  bool
+	}{{g.WhitePositions, true, opts.SkipWhite}, {g.BlackPositions, false, opts.SkipBlack}} {
+		if root.skip {
+			continue
+		}
+		w := walker{
+			ctx:     ctx,
+			visitor: visitor,
+			opts:    opts,
+			white:   root.white,
+			visited: map[*PositionNode]bool{root.node: true},
+		}
+		if err := w.walk(root.node, make([]*Move, 0, g.Depth)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type walker struct {
+	ctx     context.Context
+	visitor Visitor
+	opts    WalkOptions
+	white   bool
+	// visited are the positions whose moves have been walked (WalkOptions.Once)
+	visited map[*PositionNode]bool
+}
+
+func (w *walker) walk(node *PositionNode, path []*Move) error {
+	for _, move := range node.Moves {
+		if err := w.ctx.Err(); err != nil {
+			return err
+		}
+		step := Step{White: w.white, From: node, Path: append(path, move)}
+		descend := true
+		if w.visitor.Pre != nil {
+			if err := w.visitor.Pre(step); errors.Is(err, SkipMoves) {
+				descend = false
+			} else if err != nil {
+				return err
+			}
+		}
+		if w.opts.MaxDepth > 0 && step.Depth() >= w.opts.MaxDepth {
+			descend = false
+		}
+		if w.opts.Once {
+			if w.visited[move.To] {
+				descend = false
+			} else if descend {
+				w.visited[move.To] = true
+			}
+		} else if repeatsPosition(path, move.To) {
+			descend = false
+		}
+		if descend {
+			if err := w.walk(move.To, step.Path); err != nil {
+				return err
+			}
+		}
+		if w.visitor.Post != nil {
+			if err := w.visitor.Post(step); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// repeatsPosition reports whether one of the moves leads to the node
+func repeatsPosition(moves []*Move, node *PositionNode) bool {
+	for _, move := range moves {
+		if move.To == node {
+			return true
+		}
+	}
+	return false
+}
+
+// VariationIterator lazily returns the move sequences of a graph, see PositionGraph.Variations.
+// It does not start any goroutines, so it can be dropped at any time
+type VariationIterator struct {
+	ctx   context.Context
+	roots []*PositionNode
+	// stack holds the moves of the positions of path that remain to be walked, the first frame is a root's
+	stack     []variationFrame
+	path      []*Move
+	variation []*Move
+	err       error
+}
+
+type variationFrame struct {
+	moves []*Move
+	next  int
+}
+
+// Variations returns an iterator over the move sequences from the first move to the last one
+// of both repertoires, the white one first. Positions shared by several move orders are part of a variation
+// for each of them. A variation that repeats a position ends with the move that repeats it
+func (g *PositionGraph) Variations(ctx context.Context) *VariationIterator {
+	return &VariationIterator{
+		ctx:   ctx,
+		roots: []*PositionNode{g.WhitePositions, g.BlackPositions},
+	}
+}
+
+// Next advances the iterator to the next variation. It returns false when the variations are over or ctx is done
+func (it *VariationIterator) Next() bool {
+	for {
+		if it.err = it.ctx.Err(); it.err != nil {
+			return false
+		}
+		if len(it.stack) == 0 {
+			if len(it.roots) == 0 {
+				return false
+			}
+			it.stack = append(it.stack, variationFrame{moves: it.roots[0].Moves})
+			it.roots = it.roots[1:]
+		}
+		top := &it.stack[len(it.stack)-1]
+		if top.next >= len(top.moves) {
+			if len(it.stack) > 1 {
+				it.path = it.path[:len(it.path)-1]
+			}
+			it.stack = it.stack[:len(it.stack)-1]
+			continue
+		}
+		move := top.moves[top.next]
+		top.next++
+		if len(move.To.Moves) == 0 || repeatsPosition(it.path, move.To) {
+			it.variation = append(append(make([]*Move, 0, len(it.path)+1), it.path...), move)
+			return true
+		}
+		it.path = append(it.path, move)
+		it.stack = append(it.stack, variationFrame{moves: move.To.Moves})
+	}
+}
+
+// Variation returns the current variation. The slice is not modified by the following calls to Next
+func (it *VariationIterator) Variation() []*Move {
+	return it.variation
+}
+
+// Err returns the error of ctx if the iteration was stopped by it
+func (it *VariationIterator) Err() error {
+	return it.err
+}
@@ -0,0 +1,143 @@
+package positions
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Hofsiedge/ChessOpeningAnalyzer/internal/fetching"
+)
+
+func newWalkGraph(t *testing.T) *PositionGraph {
+	graph, _ := NewPositionGraph(4)
+	for _, game := range []struct {
+		white bool
+		moves string
+	}{
+		{true, "e4 e5 Nf3 Nc6"},
+		{true, "Nf3 Nc6 e4 e5"},
+		{true, "e4 c5"},
+		{false, "d4 d5"},
+	} {
+		if err := graph.AddGame(fetching.UserGame{White: game.white, Moves: strings.Split(game.moves, " ")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return graph
+}
+
+func TestPositionGraph_Walk(t *testing.T) {
+	graph := newWalkGraph(t)
+	errStop := errors.New("stop")
+	tests := []struct {
+		name string
+		opts WalkOptions
+		// skip is the move order whose following moves are skipped by the Pre hook
+		skip string
+		// stop is the move order at which the Pre hook returns an error
+		stop string
+		want []string
+	}{{
+		name: "All",
+		want: []string{
+			"+e4", "+e4 e5", "+e4 e5 Nf3", "+e4 e5 Nf3 Nc6", "-e4 e5 Nf3 Nc6", "-e4 e5 Nf3", "-e4 e5",
+			"+e4 c5", "-e4 c5", "-e4",
+			"+Nf3", "+Nf3 Nc6", "+Nf3 Nc6 e4", "+Nf3 Nc6 e4 e5", "-Nf3 Nc6 e4 e5", "-Nf3 Nc6 e4", "-Nf3 Nc6", "-Nf3",
+			"+d4", "+d4 d5", "-d4 d5", "-d4",
+		},
+	}, {
+		name: "OnceMaxDepthSkip",
+		opts: WalkOptions{Once: true, MaxDepth: 3, SkipBlack: true},
+		skip: "e4 c5",
+		want: []string{
+			"+e4", "+e4 e5", "+e4 e5 Nf3", "-e4 e5 Nf3", "-e4 e5", "+e4 c5", "-e4 c5", "-e4",
+			"+Nf3", "+Nf3 Nc6", "+Nf3 Nc6 e4", "-Nf3 Nc6 e4", "-Nf3 Nc6", "-Nf3",
+		},
+	}, {
+		name: "Once",
+		opts: WalkOptions{Once: true, SkipWhite: true},
+		want: []string{"+d4", "+d4 d5", "-d4 d5", "-d4"},
+	}, {
+		name: "Stop",
+		stop: "e4 e5 Nf3",
+		want: []string{"+e4", "+e4 e5", "+e4 e5 Nf3"},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make([]string, 0)
+			line := func(step Step) string {
+				moves := make([]string, len(step.Path))
+				for i, move := range step.Path {
+					moves[i] = move.Move
+				}
+				return strings.Join(moves, " ")
+			}
+			err := graph.Walk(context.Background(), Visitor{
+				Pre: func(step Step) error {
+					got = append(got, "+"+line(step))
+					switch line(step) {
+					case tt.skip:
+						return SkipMoves
+					case tt.stop:
+						return errStop
+					}
+					return nil
+				},
+				Post: func(step Step) error {
+					got = append(got, "-"+line(step))
+					return nil
+				},
+			}, tt.opts)
+			if tt.stop != "" && !errors.Is(err, errStop) || tt.stop == "" && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	steps := 0
+	err := graph.Walk(ctx, Visitor{Pre: func(step Step) error {
+		steps++
+		cancel()
+		return nil
+	}}, WalkOptions{})
+	if !errors.Is(err, context.Canceled) || steps != 1 {
+		t.Errorf("expected the walk to stop after the first step, got %v steps and %v", steps, err)
+	}
+}
+
+func TestPositionGraph_Variations(t *testing.T) {
+	graph := newWalkGraph(t)
+	expected := []string{"e4 e5 Nf3 Nc6", "e4 c5", "Nf3 Nc6 e4 e5", "d4 d5"}
+	variations := make([]string, 0)
+	it := graph.Variations(context.Background())
+	for it.Next() {
+		moves := make([]string, 0)
+		for _, move := range it.Variation() {
+			moves = append(moves, move.Move)
+		}
+		variations = append(variations, strings.Join(moves, " "))
+	}
+	if it.Err() != nil || !reflect.DeepEqual(variations, expected) {
+		t.Errorf("expected variations %v, got %v (%v)", expected, variations, it.Err())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	it = graph.Variations(ctx)
+	if !it.Next() {
+		t.Fatal("expected a variation")
+	}
+	first := it.Variation()
+	cancel()
+	if it.Next() || !errors.Is(it.Err(), context.Canceled) {
+		t.Errorf("expected the iteration to stop with the context, got %v", it.Err())
+	}
+	if len(first) != 4 || first[3].Move != "Nc6" {
+		t.Errorf("expected the first variation to be kept, got %v", first)
+	}
+}
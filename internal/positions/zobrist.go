@@ -0,0 +1,165 @@
+package positions
+
+import (
+	"fmt"
+	"math/bits"
+	"strings"
+
+	"github.com/notnil/chess"
+)
+
+// Key is a 64-bit Zobrist hash of a position: the placement of the pieces, the side to move, the castling rights
+// and the en passant file if an en passant capture is legal. Move counters are not taken into account.
+// Keys are stored in graph files, so the hashing scheme must never change
+type Key uint64
+
+// zobrist holds the random numbers that make up the keys
+var zobrist struct {
+	pieces    [12][64]uint64
+	blackMove uint64
+	castling  [4]uint64
+	enPassant [8]uint64
+}
+
+func init() {
+	// splitmix64 with a fixed seed, so that the keys do not depend on the standard library
+	state := uint64(0x4f70656e696e6773)
+	next := func() uint64 {
+		state += 0x9e3779b97f4a7c15
+		z := state
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		return z ^ (z >> 31)
+	}
+	for piece := range zobrist.pieces {
+		for square := range zobrist.pieces[piece] {
+			zobrist.pieces[piece][square] = next()
+		}
+	}
+	zobrist.blackMove = next()
+	for i := range zobrist.castling {
+		zobrist.castling[i] = next()
+	}
+	for i := range zobrist.enPassant {
+		zobrist.enPassant[i] = next()
+	}
+}
+
+// NewKey computes the key of the position
+func NewKey(pos *chess.Position) Key {
+	var key uint64
+	// the bitboards of the pieces in the order of chess.Board.MarshalBinary
+	data, _ := pos.Board().MarshalBinary()
+	for piece := range zobrist.pieces {
+		var bitboard uint64
+		for _, b := range data[piece*8 : piece*8+8] {
+			bitboard = bitboard<<8 | uint64(b)
+		}
+		for bitboard != 0 {
+			square := bits.TrailingZeros64(bitboard)
+			key ^= zobrist.pieces[piece][square]
+			bitboard &= bitboard - 1
+		}
+	}
+	if pos.Turn() == chess.Black {
+		key ^= zobrist.blackMove
+	}
+	rights := pos.CastleRights()
+	for i, right := range []struct {
+		color chess.Color
+		side  chess.Side
+	}{{chess.White, chess.KingSide}, {chess.White, chess.QueenSide}, {chess.Black, chess.KingSide}, {chess.Black, chess.QueenSide}} {
+		if rights.CanCastle(right.color, right.side) {
+			key ^= zobrist.castling[i]
+		}
+	}
+	if enPassantCapturable(pos) {
+		key ^= zobrist.enPassant[pos.EnPassantSquare().File()]
+	}
+	return Key(key)
+}
+
+// enPassantCapturable reports whether the side to move can legally capture en passant.
+// The en passant square is set after every double pawn push, even if there is no pawn to capture with
+func enPassantCapturable(pos *chess.Position) bool {
+	square := pos.EnPassantSquare()
+	if square == chess.NoSquare {
+		return false
+	}
+	// the capturing pawns stand next to the pushed one, a rank below the en passant square for white
+	rank, pawn := chess.Rank5, chess.WhitePawn
+	if pos.Turn() == chess.Black {
+		rank, pawn = chess.Rank4, chess.BlackPawn
+	}
+	adjacent := false
+	for _, file := range []chess.File{square.File() - 1, square.File() + 1} {
+		if file >= chess.FileA && file <= chess.FileH && pos.Board().Piece(chess.NewSquare(file, rank)) == pawn {
+			adjacent = true
+		}
+	}
+	if !adjacent {
+		return false
+	}
+	// the capture may still leave the king in check
+	for _, move := range pos.ValidMoves() {
+		if move.HasTag(chess.EnPassant) {
+			return true
+		}
+	}
+	return false
+}
+
+// Key computes the key of a complete or a truncated FEN (see FEN.Full)
+func (f FEN) Key() (Key, error) {
+	fen, err := chess.FEN(f.Full())
+	if err != nil {
+		return 0, fmt.Errorf("positions.FEN.Key: %w", err)
+	}
+	return NewKey(chess.NewGame(fen).Position()), nil
+}
+
+// playMove returns the position after a move in SAN. Unambiguous moves are found among the valid ones by the piece
+// and the destination square, which is a lot faster than decoding them with chess.AlgebraicNotation that encodes
+// every valid move. Unlike chess.Game, positions do not look for repetitions in the whole game after each move
+func playMove(pos *chess.Position, san string) (*chess.Position, error) {
+	move := findMove(pos, san)
+	if move == nil {
+		var err error
+		if move, err = (chess.AlgebraicNotation{}).Decode(pos, san); err != nil {
+			return nil, err
+		}
+	}
+	return pos.Update(move), nil
+}
+
+// findMove returns the only valid move that matches the piece and the destination of the move in SAN or nil
+func findMove(pos *chess.Position, san string) *chess.Move {
+	san = strings.TrimRight(san, "+#!?")
+	var found *chess.Move
+	matches := func(move *chess.Move) bool {
+		switch san {
+		case "O-O":
+			return move.HasTag(chess.KingSideCastle)
+		case "O-O-O":
+			return move.HasTag(chess.QueenSideCastle)
+		}
+		if len(san) < 2 || strings.Contains(san, "=") {
+			return false
+		}
+		pieceType := chess.Pawn
+		if i := strings.IndexByte("KQRBN", san[0]); i >= 0 {
+			pieceType = []chess.PieceType{chess.King, chess.Queen, chess.Rook, chess.Bishop, chess.Knight}[i]
+		}
+		return move.S2().String() == san[len(san)-2:] && pos.Board().Piece(move.S1()).Type() == pieceType
+	}
+	for _, move := range pos.ValidMoves() {
+		if !matches(move) {
+			continue
+		}
+		if found != nil {
+			return nil
+		}
+		found = move
+	}
+	return found
+}
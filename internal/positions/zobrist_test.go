@@ -0,0 +1,194 @@
+package positions
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Hofsiedge/ChessOpeningAnalyzer/internal/fetching"
+	"github.com/notnil/chess"
+)
+
+func lineKey(t testing.TB, moves string) Key {
+	t.Helper()
+	position, err := LinePosition(strings.Split(moves, " "))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return position.Key
+}
+
+func fenKey(t testing.TB, fen FEN) Key {
+	t.Helper()
+	key, err := fen.Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestNewKey(t *testing.T) {
+	tests := []struct {
+		name  string
+		a, b  Key
+		equal bool
+	}{{
+		name:  "Transposition",
+		a:     lineKey(t, "e4 e5 Nf3 Nc6"),
+		b:     lineKey(t, "Nf3 Nc6 e4 e5"),
+		equal: true,
+	}, {
+		name:  "SideToMove",
+		a:     fenKey(t, "rnbqkbnr/pppppppp/8/8/8/5N2/PPPPPPPP/RNBQKB1R b KQkq"),
+		b:     fenKey(t, "rnbqkbnr/pppppppp/8/8/8/5N2/PPPPPPPP/RNBQKB1R w KQkq"),
+		equal: false,
+	}, {
+		name:  "CastlingRights",
+		a:     lineKey(t, "Nf3 Nf6 Rg1 Ng8 Rh1 Nf6"),
+		b:     lineKey(t, "Nf3 Nf6"),
+		equal: false,
+	}, {
+		// there is no black pawn to capture the e4 pawn
+		name:  "EnPassantNotCapturable",
+		a:     lineKey(t, "e4"),
+		b:     fenKey(t, "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 1"),
+		equal: true,
+	}, {
+		name:  "EnPassantCapturable",
+		a:     lineKey(t, "e4 d5 e5 f5"),
+		b:     fenKey(t, "rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq - 0 3"),
+		equal: false,
+	}, {
+		name:  "EnPassantAfterTransposition",
+		a:     lineKey(t, "e4 d5 e5 f5"),
+		b:     lineKey(t, "e4 f5 e5 d5"),
+		equal: false,
+	}, {
+		// the capture would expose the king to the rook
+		name:  "EnPassantPinned",
+		a:     fenKey(t, "4k3/8/8/KPp4r/8/8/8/8 w - c6 0 2"),
+		b:     fenKey(t, "4k3/8/8/KPp4r/8/8/8/8 w - - 0 2"),
+		equal: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if (tt.a == tt.b) != tt.equal {
+				t.Errorf("expected equal keys: %v, got %x and %x", tt.equal, tt.a, tt.b)
+			}
+		})
+	}
+
+	if _, err := FEN("not a fen").Key(); err == nil {
+		t.Error("expected an error for an invalid FEN")
+	}
+	if key := fenKey(t, FEN(chess.StartingPosition().String())); key != NewKey(chess.StartingPosition()) {
+		t.Errorf("expected the key of the starting FEN to match the starting position")
+	}
+}
+
+func TestPlayMove(t *testing.T) {
+	tests := []struct {
+		name string
+		fen  string
+		move string
+	}{
+		{"Pawn", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "e4"},
+		{"Check", "rnbqkbnr/ppppp1pp/8/5p2/4P3/8/PPPP1PPP/RNBQKBNR w KQkq - 0 2", "Qh5+"},
+		{"Ambiguous", "4k3/8/8/8/8/8/8/1N2KN2 w - - 0 1", "Nbd2"},
+		{"Castling", "r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", "O-O-O"},
+		{"Promotion", "8/4P3/8/8/8/8/k7/4K3 w - - 0 1", "e8=N"},
+		{"EnPassant", "rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq f6 0 3", "exf6"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fen, err := chess.FEN(tt.fen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected := chess.NewGame(fen)
+			if err = expected.MoveStr(tt.move); err != nil {
+				t.Fatal(err)
+			}
+			pos, err := playMove(chess.NewGame(fen).Position(), tt.move)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := pos.String(), expected.Position().String(); got != want {
+				t.Errorf("expected %v, got %v", want, got)
+			}
+		})
+	}
+	if _, err := playMove(chess.StartingPosition(), "Nd2"); err == nil {
+		t.Error("expected an error for an illegal move")
+	}
+}
+
+// benchmarkLine is a typical opening line with castling, captures and checks
+var benchmarkLine = strings.Split("e4 c5 Nf3 d6 d4 cxd4 Nxd4 Nf6 Nc3 a6 Be3 e5 Nb3 Be6 f3 Be7 Qd2 O-O O-O-O Nbd7 g4 b5 g5 b4 Ne2 Ne8", " ")
+
+// BenchmarkPositionKey compares identifying a position by a truncated FEN and by a Zobrist key
+func BenchmarkPositionKey(b *testing.B) {
+	game := chess.NewGame()
+	for _, move := range benchmarkLine {
+		if err := game.MoveStr(move); err != nil {
+			b.Fatal(err)
+		}
+	}
+	position := game.Position()
+	b.Run("FEN", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = TruncateFEN(position.String())
+		}
+	})
+	b.Run("Zobrist", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = NewKey(position)
+		}
+	})
+}
+
+// BenchmarkPlayMove compares playing the moves of a line with chess.Game.MoveStr and with playMove
+func BenchmarkPlayMove(b *testing.B) {
+	b.Run("MoveStr", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			game := chess.NewGame()
+			for _, move := range benchmarkLine {
+				if err := game.MoveStr(move); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+	b.Run("playMove", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			pos := chess.StartingPosition()
+			for _, move := range benchmarkLine {
+				var err error
+				if pos, err = playMove(pos, move); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+}
+
+func BenchmarkPositionGraph_AddGame(b *testing.B) {
+	games := []fetching.UserGame{
+		{White: true, Moves: benchmarkLine},
+		{White: true, Moves: strings.Split("e4 e5 Nf3 Nc6 Bb5 a6 Ba4 Nf6 O-O Be7 Re1 b5 Bb3 d6 c3 O-O", " ")},
+		{White: false, Moves: strings.Split("d4 Nf6 c4 e6 Nc3 Bb4 e3 O-O Bd3 d5 Nf3 c5 O-O Nc6 a3 Bxc3", " ")},
+		{White: false, Moves: strings.Split("Nf3 d5 g3 Nf6 Bg2 e6 O-O Be7 d3 O-O Nbd2 c5 e4 Nc6 Re1 b5", " ")},
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		graph, _ := NewPositionGraph(len(benchmarkLine))
+		for _, game := range games {
+			if err := graph.AddGame(game); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}